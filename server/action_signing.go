@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const actionSecretKVKey = "onboarding:action_secret"
+
+// Actions a checklist or signature preview button can trigger
+const (
+	actionToggleStep          = "toggle_step"
+	actionOpenSignatureDialog = "open_signature_dialog"
+	actionOpenIntroDialog     = "open_intro_dialog"
+	actionStartQuiz           = "start_quiz"
+	actionSendSignature       = "send_signature"
+	actionEditSignature       = "edit_signature"
+	// actionAcknowledgePolicy carries "policyID@version" as its step
+	actionAcknowledgePolicy = "acknowledge_policy"
+)
+
+// Keys of a signed button context
+const (
+	contextKeyAction    = "action"
+	contextKeyStep      = "step"
+	contextKeyExpires   = "expires"
+	contextKeySignature = "signature"
+)
+
+var (
+	// errActionSignatureInvalid means the context wasn't signed by this plugin
+	// for this user and post, or was altered
+	errActionSignatureInvalid = errors.New("invalid action signature")
+	// errActionSignatureExpired means the signature was valid but is too old
+	errActionSignatureExpired = errors.New("action signature expired")
+	// errActionSignatureMissing means the post was rendered before buttons
+	// were signed
+	errActionSignatureMissing = errors.New("action signature missing")
+)
+
+// ensureActionSecret loads the key used to sign button contexts, creating it
+// on first activation. All cluster nodes share the same key.
+func (p *Plugin) ensureActionSecret() error {
+	secret, appErr := p.API.KVGet(actionSecretKVKey)
+	if appErr != nil {
+		return fmt.Errorf("KVGet: %w", appErr)
+	}
+
+	if secret == nil {
+		generated := make([]byte, 32)
+		if _, err := rand.Read(generated); err != nil {
+			return fmt.Errorf("generate action secret: %w", err)
+		}
+		// Another node may be activating at the same time; keep whichever key
+		// was stored first
+		if _, appErr := p.API.KVSetWithOptions(actionSecretKVKey, generated, model.PluginKVSetOptions{Atomic: true, OldValue: nil}); appErr != nil {
+			return fmt.Errorf("KVSetWithOptions: %w", appErr)
+		}
+		if secret, appErr = p.API.KVGet(actionSecretKVKey); appErr != nil {
+			return fmt.Errorf("KVGet: %w", appErr)
+		}
+	}
+
+	if len(secret) == 0 {
+		return errors.New("action secret is empty")
+	}
+	p.actionSecret = secret
+	return nil
+}
+
+// actionSignatureTTL is how long a signed button stays valid
+func (p *Plugin) actionSignatureTTL() time.Duration {
+	days := p.getPluginIntSetting("ActionSignatureTTLDays", 30)
+	if days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// signActionContext builds a button context whose action and step can't be
+// changed or replayed on another user's post
+func (p *Plugin) signActionContext(userID, postID, action, step string) map[string]interface{} {
+	expires := strconv.FormatInt(time.Now().Add(p.actionSignatureTTL()).Unix(), 10)
+	return map[string]interface{}{
+		contextKeyAction:    action,
+		contextKeyStep:      step,
+		contextKeyExpires:   expires,
+		contextKeySignature: p.actionSignature(userID, postID, action, step, expires),
+	}
+}
+
+// verifyActionContext checks a button context signed by signActionContext and
+// returns its action and step
+func (p *Plugin) verifyActionContext(userID, postID string, context map[string]interface{}) (string, string, error) {
+	action, _ := context[contextKeyAction].(string)
+	step, _ := context[contextKeyStep].(string)
+	expires, _ := context[contextKeyExpires].(string)
+	signature, _ := context[contextKeySignature].(string)
+	if signature == "" {
+		return "", "", errActionSignatureMissing
+	}
+
+	got, err := hex.DecodeString(signature)
+	if err != nil || len(p.actionSecret) == 0 {
+		return "", "", errActionSignatureInvalid
+	}
+	want, _ := hex.DecodeString(p.actionSignature(userID, postID, action, step, expires))
+	if !hmac.Equal(got, want) {
+		return "", "", errActionSignatureInvalid
+	}
+
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return "", "", errActionSignatureInvalid
+	}
+	if time.Now().Unix() > expiresAt {
+		return "", "", errActionSignatureExpired
+	}
+	return action, step, nil
+}
+
+// actionSignature is the hex HMAC-SHA256 over the fields of a button context
+func (p *Plugin) actionSignature(userID, postID, action, step, expires string) string {
+	mac := hmac.New(sha256.New, p.actionSecret)
+	mac.Write([]byte(strings.Join([]string{userID, postID, action, step, expires}, "\x00")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
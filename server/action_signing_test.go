@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+)
+
+func TestVerifyActionContext(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("GetConfig").Return(&model.Config{})
+	p := &Plugin{actionSecret: []byte("test secret")}
+	p.SetAPI(api)
+
+	const userID, postID = "user1", "post1"
+	signed := p.signActionContext(userID, postID, actionToggleStep, "accounts")
+
+	// with returns a copy of the signed context with one key changed
+	with := func(key string, value interface{}) map[string]interface{} {
+		context := map[string]interface{}{}
+		for k, v := range signed {
+			context[k] = v
+		}
+		context[key] = value
+		return context
+	}
+	expired := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	expiredContext := with(contextKeyExpires, expired)
+	expiredContext[contextKeySignature] = p.actionSignature(userID, postID, actionToggleStep, "accounts", expired)
+
+	tests := []struct {
+		name    string
+		userID  string
+		postID  string
+		context map[string]interface{}
+		err     error
+	}{
+		{name: "valid", userID: userID, postID: postID, context: signed},
+		{name: "other user", userID: "user2", postID: postID, context: signed, err: errActionSignatureInvalid},
+		{name: "other post", userID: userID, postID: "post2", context: signed, err: errActionSignatureInvalid},
+		{name: "changed step", userID: userID, postID: postID, context: with(contextKeyStep, "signature"), err: errActionSignatureInvalid},
+		{name: "changed action", userID: userID, postID: postID, context: with(contextKeyAction, actionStartQuiz), err: errActionSignatureInvalid},
+		{name: "extended expiry", userID: userID, postID: postID, context: with(contextKeyExpires, "9999999999"), err: errActionSignatureInvalid},
+		{name: "malformed signature", userID: userID, postID: postID, context: with(contextKeySignature, "not hex"), err: errActionSignatureInvalid},
+		{name: "missing signature", userID: userID, postID: postID, context: with(contextKeySignature, nil), err: errActionSignatureMissing},
+		{name: "expired", userID: userID, postID: postID, context: expiredContext, err: errActionSignatureExpired},
+	}
+	for _, test := range tests {
+		action, step, err := p.verifyActionContext(test.userID, test.postID, test.context)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: got error %v, want %v", test.name, err, test.err)
+			continue
+		}
+		if test.err == nil && (action != actionToggleStep || step != "accounts") {
+			t.Errorf("%s: got %s/%s, want %s/accounts", test.name, action, step, actionToggleStep)
+		}
+	}
+
+	// A context signed with another key is rejected
+	other := &Plugin{actionSecret: []byte("other secret")}
+	other.SetAPI(api)
+	if _, _, err := other.verifyActionContext(userID, postID, signed); !errors.Is(err, errActionSignatureInvalid) {
+		t.Errorf("other secret: got error %v, want %v", err, errActionSignatureInvalid)
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// maxCatalogSize limits uploaded translation catalogs
+const maxCatalogSize = 1 << 20
+
+// setStepRequest is the body of POST /api/v1/users/{user_id}/steps/{step}
+type setStepRequest struct {
+	Completed bool `json:"completed"`
+	// Force completes the step even if its checks, quiz or policies don't
+	// pass. The history event is marked as forced.
+	Force bool `json:"force"`
+}
+
+// setStepConflict is returned when a step's checks don't pass
+type setStepConflict struct {
+	Error   string   `json:"error"`
+	Missing []string `json:"missing"`
+}
+
+// serveAPI handles the REST API used by HR tooling. All routes require a
+// logged-in system admin.
+func (p *Plugin) serveAPI(w http.ResponseWriter, r *http.Request, actorID string) {
+	if !p.API.HasPermissionTo(actorID, model.PermissionManageSystem) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/users/{user_id}/history", p.handleGetHistory)
+	mux.HandleFunc("GET /api/v1/users/{user_id}/policy-acknowledgements", p.handleGetPolicyAcknowledgements)
+	mux.HandleFunc("GET /api/v1/users/{user_id}/quiz-attempts", p.handleGetQuizAttempts)
+	mux.HandleFunc("POST /api/v1/users/{user_id}/steps/{step}", p.handleSetStep)
+	mux.HandleFunc("GET /api/v1/quizzes/{step}/stats", p.handleGetQuizStats)
+	mux.HandleFunc("GET /api/v1/i18n", p.handleListLanguages)
+	mux.HandleFunc("GET /api/v1/i18n/issues", p.handleGetCatalogIssues)
+	mux.HandleFunc("GET /api/v1/i18n/{language}", p.handleGetCatalog)
+	mux.HandleFunc("PUT /api/v1/i18n/{language}", p.handlePutCatalogOverride)
+	mux.HandleFunc("DELETE /api/v1/i18n/{language}", p.handleDeleteCatalogOverride)
+	mux.HandleFunc("GET /api/v1/signature-templates", p.handleListTemplates)
+	mux.HandleFunc("GET /api/v1/signature-templates/{id}", p.handleGetTemplate)
+	mux.HandleFunc("PUT /api/v1/signature-templates/{id}", p.handleSaveTemplate)
+	mux.HandleFunc("GET /api/v1/signature-templates/{id}/preview", p.handlePreviewTemplate)
+	mux.HandleFunc("POST /api/v1/signature-templates/{id}/retire", p.handleRetireTemplate(true))
+	mux.HandleFunc("POST /api/v1/signature-templates/{id}/restore", p.handleRetireTemplate(false))
+	mux.HandleFunc("POST /api/v1/signature-templates/{id}/rollback", p.handleRollbackTemplate)
+	mux.HandleFunc("POST /api/v1/signature-templates/{id}/resend", p.handleResendTemplate)
+	mux.ServeHTTP(w, r)
+}
+
+func (p *Plugin) handleGetHistory(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("user_id")
+
+	events, err := p.loadStepEvents(userID)
+	if err != nil {
+		p.API.LogError("failed to load onboarding history", "user_id", userID, "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if events == nil {
+		events = []StepEvent{}
+	}
+
+	p.writeJSON(w, http.StatusOK, events)
+}
+
+// handleGetPolicyAcknowledgements exports a user's acknowledgements as proof
+// of which policy versions they accepted and when
+func (p *Plugin) handleGetPolicyAcknowledgements(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("user_id")
+
+	acks, err := p.loadPolicyAcknowledgements(userID)
+	if err != nil {
+		p.API.LogError("failed to load policy acknowledgements", "user_id", userID, "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if acks == nil {
+		acks = []PolicyAcknowledgement{}
+	}
+
+	p.writeJSON(w, http.StatusOK, acks)
+}
+
+// handleGetQuizAttempts returns a user's quiz attempts with their answers
+func (p *Plugin) handleGetQuizAttempts(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("user_id")
+
+	attempts, err := p.loadQuizAttempts(userID)
+	if err != nil {
+		p.API.LogError("failed to load quiz attempts", "user_id", userID, "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if attempts == nil {
+		attempts = []QuizAttempt{}
+	}
+
+	p.writeJSON(w, http.StatusOK, attempts)
+}
+
+// handleGetQuizStats reports pass rates per question of a step's quiz
+func (p *Plugin) handleGetQuizStats(w http.ResponseWriter, r *http.Request) {
+	quiz, ok := findQuiz(p.getQuizzes(), r.PathValue("step"))
+	if !ok {
+		http.Error(w, "no quiz for this step", http.StatusNotFound)
+		return
+	}
+
+	stats, err := p.collectQuizStats(quiz)
+	if err != nil {
+		p.API.LogError("failed to collect quiz stats", "step", quiz.Step, "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	p.writeJSON(w, http.StatusOK, stats)
+}
+
+func (p *Plugin) handleSetStep(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("user_id")
+	step := r.PathValue("step")
+
+	var req setStepRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	state, err := p.loadState(userID)
+	if err != nil {
+		p.API.LogError("failed to load onboarding state", "user_id", userID, "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if state == nil {
+		http.Error(w, "onboarding not started", http.StatusNotFound)
+		return
+	}
+	if _, ok := findStep(p.stepsForState(state), step); !ok {
+		http.Error(w, "unknown step", http.StatusBadRequest)
+		return
+	}
+
+	// The same gates as the checklist button apply unless the caller forces
+	// the change. Checks run for every open step, so others may complete too.
+	forced := false
+	if req.Completed && !state.CompletedSteps[step] {
+		_, missing := p.verifyOpenSteps(user, state)
+		if failed := missing[step]; len(failed) > 0 {
+			if !req.Force {
+				names := make([]string, 0, len(failed))
+				for _, check := range failed {
+					names = append(names, check.String())
+				}
+				p.refreshChecklistPost(user, state)
+				p.writeJSON(w, http.StatusConflict, setStepConflict{Error: "step checks not passed", Missing: names})
+				return
+			}
+			forced = true
+		}
+	}
+
+	if state.CompletedSteps[step] != req.Completed {
+		event := StepEvent{Step: step, ActorID: r.Header.Get(headerUserID), Source: eventSourceAPI, Forced: forced}
+		if err := p.applyStepChange(state, req.Completed, event); err != nil {
+			p.API.LogError("failed to save onboarding state", "user_id", userID, "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	p.refreshChecklistPost(user, state)
+
+	p.writeJSON(w, http.StatusOK, state)
+}
+
+func (p *Plugin) handleListLanguages(w http.ResponseWriter, r *http.Request) {
+	p.writeJSON(w, http.StatusOK, p.supportedLanguages())
+}
+
+// handleGetCatalogIssues reports the problems of every catalog
+func (p *Plugin) handleGetCatalogIssues(w http.ResponseWriter, r *http.Request) {
+	catalogs, err := p.loadCatalogs()
+	if err != nil {
+		p.API.LogError("failed to load translation catalogs", "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	issues := validateCatalogs(catalogs, referenceLanguage)
+	if issues == nil {
+		issues = []catalogIssue{}
+	}
+	p.writeJSON(w, http.StatusOK, issues)
+}
+
+// handleGetCatalog returns the effective catalog of a language, including
+// admin overrides but without the English fallback
+func (p *Plugin) handleGetCatalog(w http.ResponseWriter, r *http.Request) {
+	catalogs, err := p.loadCatalogs()
+	if err != nil {
+		p.API.LogError("failed to load translation catalogs", "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	catalog, ok := catalogs[r.PathValue("language")]
+	if !ok {
+		http.Error(w, "unknown language", http.StatusNotFound)
+		return
+	}
+	p.writeJSON(w, http.StatusOK, catalog)
+}
+
+// handlePutCatalogOverride stores an override catalog. It replaces any
+// earlier override of the language; keys it leaves out use the bundled text.
+// The response lists the problems left in the merged catalog.
+func (p *Plugin) handlePutCatalogOverride(w http.ResponseWriter, r *http.Request) {
+	language := r.PathValue("language")
+	if !languageCodePattern.MatchString(language) {
+		http.Error(w, "invalid language code", http.StatusBadRequest)
+		return
+	}
+
+	var catalog messageCatalog
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCatalogSize)).Decode(&catalog); err != nil {
+		http.Error(w, "invalid catalog: expected a JSON object of message IDs to strings", http.StatusBadRequest)
+		return
+	}
+
+	if err := p.saveCatalogOverride(language, catalog); err != nil {
+		p.API.LogError("failed to save translation override", "language", language, "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := p.publishTranslationsChanged(); err != nil {
+		p.API.LogError("failed to reload translations", "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	catalogs, err := p.loadCatalogs()
+	if err != nil {
+		p.API.LogError("failed to load translation catalogs", "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	issues := validateCatalog(language, catalogs[language], catalogs[referenceLanguage])
+	if issues == nil {
+		issues = []catalogIssue{}
+	}
+	p.writeJSON(w, http.StatusOK, issues)
+}
+
+func (p *Plugin) handleDeleteCatalogOverride(w http.ResponseWriter, r *http.Request) {
+	language := r.PathValue("language")
+	if err := p.deleteCatalogOverride(language); err != nil {
+		p.API.LogError("failed to delete translation override", "language", language, "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := p.publishTranslationsChanged(); err != nil {
+		p.API.LogError("failed to reload translations", "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (p *Plugin) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		p.API.LogError("failed to encode API response", "err", err.Error())
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+// headerUserID is set by the Mattermost server to the id of the logged-in
+// user making a plugin request. Clients can't forge it; request bodies they
+// can.
+const headerUserID = "Mattermost-User-Id"
+
+var (
+	// errNotChecklistPost means the post isn't the bot's checklist DM to the user
+	errNotChecklistPost = errors.New("post is not the user's checklist")
+	// errOutdatedChecklistPost means the user has a newer checklist post
+	errOutdatedChecklistPost = errors.New("post is an outdated checklist")
+)
+
+// requireUser wraps a handler so that it only runs for requests the server
+// authenticated. The handler receives the authenticated user id.
+func requireUser(next func(w http.ResponseWriter, r *http.Request, userID string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Header.Get(headerUserID)
+		if userID == "" {
+			http.Error(w, "not authorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r, userID)
+	}
+}
+
+// verifyActingUser checks that the user id a client put in a request body
+// is the authenticated user. It writes a 403 response and logs the attempt
+// if not.
+func (p *Plugin) verifyActingUser(w http.ResponseWriter, r *http.Request, userID, claimedUserID string) bool {
+	if claimedUserID == userID {
+		return true
+	}
+
+	p.API.LogWarn("rejected request with mismatched user id", "path", r.URL.Path, "user_id", userID, "claimed_user_id", claimedUserID)
+	http.Error(w, "forbidden", http.StatusForbidden)
+	return false
+}
+
+// verifyChecklistPost checks that postID is a checklist the bot sent the
+// user and that it's the one stored on the user's state.
+func (p *Plugin) verifyChecklistPost(userID, postID string, state *OnboardingState) error {
+	if state == nil || postID == "" {
+		return errNotChecklistPost
+	}
+
+	post, appErr := p.API.GetPost(postID)
+	if appErr != nil || post.UserId != p.botUserID {
+		return errNotChecklistPost
+	}
+
+	channel, appErr := p.API.GetDirectChannel(p.botUserID, userID)
+	if appErr != nil || post.ChannelId != channel.Id {
+		return errNotChecklistPost
+	}
+
+	// States from before checklist posts were tracked have no post id yet
+	if state.ChecklistPostID != "" && state.ChecklistPostID != postID {
+		return errOutdatedChecklistPost
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	autoJoinReportedKVPrefix = "onboarding:autojoin_reported:"
+
+	// autoJoinReportIntervalSeconds limits reports of the same channel problem
+	// to one a day, however many users run into it
+	autoJoinReportIntervalSeconds = 24 * 60 * 60
+)
+
+// AutoJoinRule lists channels users are added to when onboarding starts.
+// Every non-empty filter must match; within a filter, any value is enough.
+type AutoJoinRule struct {
+	Teams    []string `json:"teams,omitempty"`
+	Tracks   []string `json:"tracks,omitempty"`
+	Channels []string `json:"channels"`
+}
+
+// parseAutoJoinRules parses and validates the AutoJoinChannels setting
+func parseAutoJoinRules(raw string) ([]AutoJoinRule, error) {
+	var rules []AutoJoinRule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		return nil, fmt.Errorf("parse auto-join channels: %w", err)
+	}
+	for i, rule := range rules {
+		if len(rule.Channels) == 0 {
+			return nil, fmt.Errorf("auto-join rule %d: at least one channel is required", i+1)
+		}
+		for _, channel := range rule.Channels {
+			if strings.TrimPrefix(strings.TrimSpace(channel), "~") == "" {
+				return nil, fmt.Errorf("auto-join rule %d: empty channel name", i+1)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// getAutoJoinRules returns the configured rules. An empty or invalid setting
+// means no channels are joined.
+func (p *Plugin) getAutoJoinRules() []AutoJoinRule {
+	raw := strings.TrimSpace(p.getPluginSetting("AutoJoinChannels", ""))
+	if raw == "" {
+		return nil
+	}
+
+	rules, err := parseAutoJoinRules(raw)
+	if err != nil {
+		p.API.LogDebug("ignoring auto-join channels", "err", err.Error())
+		return nil
+	}
+	return rules
+}
+
+// autoJoinChannelNames returns the channels of every rule matching the team
+// and track, in order and without duplicates
+func autoJoinChannelNames(rules []AutoJoinRule, teamName, track string) []string {
+	var names []string
+	seen := map[string]struct{}{}
+	for _, rule := range rules {
+		if len(rule.Teams) > 0 && !containsFold(rule.Teams, teamName) {
+			continue
+		}
+		if len(rule.Tracks) > 0 && !containsFold(rule.Tracks, track) {
+			continue
+		}
+		for _, name := range rule.Channels {
+			name = strings.TrimPrefix(strings.TrimSpace(name), "~")
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			seen[name] = struct{}{}
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// autoJoinTeamChannels adds the user to the configured channels of a team and
+// returns the names of the channels it joined. Channels that are missing or
+// archived are reported to the system admins.
+func (p *Plugin) autoJoinTeamChannels(user *model.User, state *OnboardingState, team *model.Team) []string {
+	var joined []string
+	for _, name := range autoJoinChannelNames(p.getAutoJoinRules(), team.Name, state.Track) {
+		channel, appErr := p.API.GetChannelByName(team.Id, name, true)
+		if appErr != nil && appErr.StatusCode == http.StatusNotFound {
+			p.reportAutoJoinProblem(team, name, false)
+			continue
+		}
+		if appErr != nil {
+			p.API.LogWarn("failed to get auto-join channel", "team_id", team.Id, "channel", name, "err", appErr.Error())
+			continue
+		}
+		if channel.DeleteAt != 0 {
+			p.reportAutoJoinProblem(team, name, true)
+			continue
+		}
+
+		if _, appErr := p.API.GetChannelMember(channel.Id, user.Id); appErr == nil {
+			continue
+		}
+		if _, appErr := p.API.AddChannelMember(channel.Id, user.Id); appErr != nil {
+			p.API.LogWarn("failed to auto-join channel", "user_id", user.Id, "channel_id", channel.Id, "err", appErr.Error())
+			continue
+		}
+		joined = append(joined, channel.Name)
+	}
+	return joined
+}
+
+// autoJoinChannels runs autoJoinTeamChannels for every team of the user
+func (p *Plugin) autoJoinChannels(user *model.User, state *OnboardingState) []string {
+	teams, appErr := p.API.GetTeamsForUser(user.Id)
+	if appErr != nil {
+		p.API.LogWarn("failed to get teams for auto-join", "user_id", user.Id, "err", appErr.Error())
+		return nil
+	}
+	var joined []string
+	for _, team := range teams {
+		joined = append(joined, p.autoJoinTeamChannels(user, state, team)...)
+	}
+	return joined
+}
+
+// recordAutoJoinedChannels notes the joined channels on the state for the
+// welcome message
+func (p *Plugin) recordAutoJoinedChannels(state *OnboardingState, joined []string) error {
+	return p.updateState(state, func(s *OnboardingState) {
+		for _, name := range joined {
+			if !containsFold(s.AutoJoinedChannels, name) {
+				s.AutoJoinedChannels = append(s.AutoJoinedChannels, name)
+			}
+		}
+	})
+}
+
+// reportAutoJoinProblem DMs the system admins about a configured channel
+// that can't be joined, at most once a day per channel
+func (p *Plugin) reportAutoJoinProblem(team *model.Team, channelName string, archived bool) {
+	p.API.LogWarn("auto-join channel unavailable", "team", team.Name, "channel", channelName, "archived", archived)
+
+	key := autoJoinReportedKVPrefix + team.Id + ":" + channelName
+	claimed, appErr := p.API.KVSetWithOptions(key, []byte("1"), model.PluginKVSetOptions{
+		Atomic:          true,
+		OldValue:        nil,
+		ExpireInSeconds: autoJoinReportIntervalSeconds,
+	})
+	if appErr != nil {
+		p.API.LogWarn("failed to record auto-join report", "err", appErr.Error())
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	admins, appErr := p.API.GetUsers(&model.UserGetOptions{Role: model.SystemAdminRoleId, Active: true, PerPage: 200})
+	if appErr != nil {
+		p.API.LogWarn("failed to get system admins", "err", appErr.Error())
+		return
+	}
+	for _, admin := range admins {
+		if admin.IsBot {
+			continue
+		}
+		tr := p.translationsForUser(admin.Id)
+		message := tr.AutoJoinChannelMissing
+		if archived {
+			message = tr.AutoJoinChannelArchived
+		}
+		if err := p.sendBotDM(admin.Id, fmt.Sprintf(message, channelName, team.DisplayName)); err != nil {
+			p.API.LogWarn("failed to report auto-join problem", "user_id", admin.Id, "err", err.Error())
+		}
+	}
+}
+
+// autoJoinedChannelsLine lists the channels the user was added to, for the
+// welcome message
+func autoJoinedChannelsLine(state *OnboardingState, tr Translations) string {
+	if state == nil || len(state.AutoJoinedChannels) == 0 {
+		return ""
+	}
+	mentions := make([]string, 0, len(state.AutoJoinedChannels))
+	for _, name := range state.AutoJoinedChannels {
+		mentions = append(mentions, "~"+name)
+	}
+	return fmt.Sprintf(tr.WelcomeAutoJoined, strings.Join(mentions, ", "))
+}
+
+// autoJoinOnTeamJoin adds a user who is still onboarding to the channels of a
+// team they joined later
+func (p *Plugin) autoJoinOnTeamJoin(user *model.User, team *model.Team) error {
+	state, err := p.loadState(user.Id)
+	if err != nil {
+		return err
+	}
+	if state == nil || !state.CompletedAt.IsZero() {
+		return nil
+	}
+
+	joined := p.autoJoinTeamChannels(user, state, team)
+	if len(joined) == 0 {
+		return nil
+	}
+
+	if err := p.recordAutoJoinedChannels(state, joined); err != nil {
+		return err
+	}
+	p.refreshChecklistPost(user, state)
+	return nil
+}
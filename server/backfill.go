@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// backfillOptions filter which existing users a backfill starts onboarding for
+type backfillOptions struct {
+	Team   *model.Team
+	Since  time.Time
+	DryRun bool
+}
+
+// backfillResult summarizes a backfill run
+type backfillResult struct {
+	Started int
+	Skipped int
+	Failed  int
+}
+
+// parseBackfillArgs parses [--team <name>] [--since YYYY-MM-DD] [--dry-run]
+func (p *Plugin) parseBackfillArgs(fields []string) (*backfillOptions, error) {
+	opts := &backfillOptions{}
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "--team":
+			if i+1 >= len(fields) {
+				return nil, fmt.Errorf("--team needs a team name")
+			}
+			i++
+			team, appErr := p.API.GetTeamByName(fields[i])
+			if appErr != nil {
+				return nil, fmt.Errorf("team %q not found", fields[i])
+			}
+			opts.Team = team
+		case "--since":
+			if i+1 >= len(fields) {
+				return nil, fmt.Errorf("--since needs a date (YYYY-MM-DD)")
+			}
+			i++
+			since, err := time.Parse("2006-01-02", fields[i])
+			if err != nil {
+				return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", fields[i])
+			}
+			opts.Since = since
+		case "--dry-run":
+			opts.DryRun = true
+		default:
+			return nil, fmt.Errorf("unknown option %q", fields[i])
+		}
+	}
+	return opts, nil
+}
+
+// runBackfill starts onboarding for every existing user without state that
+// matches the options. Users who already have state are skipped, so it is
+// safe to run more than once.
+func (p *Plugin) runBackfill(opts *backfillOptions) backfillResult {
+	const perPage = 200
+
+	var result backfillResult
+	teamID := ""
+	getOptions := &model.UserGetOptions{Active: true, PerPage: perPage}
+	if opts.Team != nil {
+		teamID = opts.Team.Id
+		getOptions.InTeamId = teamID
+	}
+
+	for page := 0; ; page++ {
+		getOptions.Page = page
+		users, appErr := p.API.GetUsers(getOptions)
+		if appErr != nil {
+			p.API.LogError("backfill: failed to get users", "page", page, "err", appErr.Error())
+			result.Failed++
+			return result
+		}
+
+		for _, user := range users {
+			if user.IsBot || user.DeleteAt != 0 || model.GetTimeForMillis(user.CreateAt).Before(opts.Since) {
+				result.Skipped++
+				continue
+			}
+
+			state, err := p.loadState(user.Id)
+			if err != nil {
+				p.API.LogWarn("backfill: failed to load onboarding state", "user_id", user.Id, "err", err.Error())
+				result.Failed++
+				continue
+			}
+			if state != nil {
+				result.Skipped++
+				continue
+			}
+
+			if !opts.DryRun {
+				if err := p.startOnboardingForUser(user, teamID); err != nil {
+					p.API.LogWarn("backfill: failed to start onboarding", "user_id", user.Id, "err", err.Error())
+					result.Failed++
+					continue
+				}
+			}
+			result.Started++
+		}
+
+		if len(users) < perPage {
+			return result
+		}
+	}
+}
+
+func (p *Plugin) executeAdminBackfill(args *model.CommandArgs, fields []string) (*model.CommandResponse, *model.AppError) {
+	tr := p.translationsForUser(args.UserId)
+
+	// Backfill touches every user, so it is limited to system admins
+	if !p.API.HasPermissionTo(args.UserId, model.PermissionManageSystem) {
+		return ephemeralResponse(tr.AdminPermissionDenied), nil
+	}
+
+	opts, err := p.parseBackfillArgs(fields)
+	if err != nil {
+		return ephemeralResponse(err.Error() + "\n\n" +
+			fmt.Sprintf(tr.AdminUsage, "/onboarding admin backfill [--team <name>] [--since YYYY-MM-DD] [--dry-run]")), nil
+	}
+
+	// Large servers take a while; report the result by DM when done
+	go func() {
+		result := p.runBackfill(opts)
+
+		summary := tr.AdminBackfillSummary
+		if opts.DryRun {
+			summary = tr.AdminBackfillDryRunSummary
+		}
+		if err := p.sendBotDM(args.UserId, fmt.Sprintf(summary, result.Started, result.Skipped, result.Failed)); err != nil {
+			p.API.LogWarn("backfill: failed to send summary", "user_id", args.UserId, "err", err.Error())
+		}
+	}()
+
+	return ephemeralResponse(tr.AdminBackfillStarted), nil
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+)
+
+const commandTrigger = "onboarding"
+
+// registerCommands registers the /onboarding slash command
+func (p *Plugin) registerCommands() error {
+	tr := p.getTranslations()
+
+	autocomplete := model.NewAutocompleteData(commandTrigger, "[command]", tr.CommandDescription)
+	autocomplete.AddCommand(model.NewAutocompleteData("status", "", tr.CommandStatusDescription))
+	autocomplete.AddCommand(model.NewAutocompleteData("show", "", tr.CommandShowDescription))
+	signature := model.NewAutocompleteData("signature", "[regenerate]", tr.CommandSignatureDescription)
+	signature.AddCommand(model.NewAutocompleteData("regenerate", "", tr.CommandSignatureRegenerateDescription))
+	autocomplete.AddCommand(signature)
+	manager := model.NewAutocompleteData(contactRoleManager, "@user", tr.CommandManagerDescription)
+	manager.AddTextArgument(tr.CommandContactArgument, "@user", "")
+	autocomplete.AddCommand(manager)
+	buddy := model.NewAutocompleteData(contactRoleBuddy, "@user", tr.CommandBuddyDescription)
+	buddy.AddTextArgument(tr.CommandContactArgument, "@user", "")
+	autocomplete.AddCommand(buddy)
+	language := model.NewAutocompleteData("language", "<code>|auto", tr.CommandLanguageDescription)
+	var languageItems []model.AutocompleteListItem
+	for _, code := range p.supportedLanguages() {
+		languageItems = append(languageItems, model.AutocompleteListItem{Item: code, HelpText: p.translationsFor(code).LanguageName})
+	}
+	languageItems = append(languageItems, model.AutocompleteListItem{Item: "auto", HelpText: tr.CommandLanguageAuto})
+	language.AddStaticListArgument("", true, languageItems)
+	autocomplete.AddCommand(language)
+	autocomplete.AddCommand(model.NewAutocompleteData("help", "", tr.CommandHelpDescription))
+
+	admin := model.NewAutocompleteData("admin", "[command]", tr.CommandAdminDescription)
+	start := model.NewAutocompleteData("start", "@user", tr.CommandAdminStartDescription)
+	start.AddTextArgument(tr.CommandAdminUserArgument, "@user", "")
+	admin.AddCommand(start)
+	reset := model.NewAutocompleteData("reset", "@user", tr.CommandAdminResetDescription)
+	reset.AddTextArgument(tr.CommandAdminUserArgument, "@user", "")
+	admin.AddCommand(reset)
+	complete := model.NewAutocompleteData("complete", "@user <step>", tr.CommandAdminCompleteDescription)
+	complete.AddTextArgument(tr.CommandAdminUserArgument, "@user", "")
+	complete.AddTextArgument(tr.CommandAdminStepArgument, "<step>", "")
+	admin.AddCommand(complete)
+	uncomplete := model.NewAutocompleteData("uncomplete", "@user <step>", tr.CommandAdminUncompleteDescription)
+	uncomplete.AddTextArgument(tr.CommandAdminUserArgument, "@user", "")
+	uncomplete.AddTextArgument(tr.CommandAdminStepArgument, "<step>", "")
+	admin.AddCommand(uncomplete)
+	list := model.NewAutocompleteData("list", "[--incomplete]", tr.CommandAdminListDescription)
+	list.AddStaticListArgument("", false, []model.AutocompleteListItem{
+		{Item: "--incomplete", HelpText: tr.CommandAdminListIncomplete},
+	})
+	admin.AddCommand(list)
+	history := model.NewAutocompleteData("history", "@user", tr.CommandAdminHistoryDescription)
+	history.AddTextArgument(tr.CommandAdminUserArgument, "@user", "")
+	admin.AddCommand(history)
+	track := model.NewAutocompleteData("track", "@user <track>", tr.CommandAdminTrackDescription)
+	track.AddTextArgument(tr.CommandAdminUserArgument, "@user", "")
+	track.AddTextArgument(tr.CommandAdminTrackArgument, "<track>", "")
+	admin.AddCommand(track)
+	adminManager := model.NewAutocompleteData(contactRoleManager, "@user @manager", tr.CommandAdminManagerDescription)
+	adminManager.AddTextArgument(tr.CommandAdminUserArgument, "@user", "")
+	adminManager.AddTextArgument(tr.CommandContactArgument, "@manager", "")
+	admin.AddCommand(adminManager)
+	adminBuddy := model.NewAutocompleteData(contactRoleBuddy, "@user @buddy", tr.CommandAdminBuddyDescription)
+	adminBuddy.AddTextArgument(tr.CommandAdminUserArgument, "@user", "")
+	adminBuddy.AddTextArgument(tr.CommandContactArgument, "@buddy", "")
+	admin.AddCommand(adminBuddy)
+	backfill := model.NewAutocompleteData("backfill", "[--team <name>] [--since YYYY-MM-DD] [--dry-run]", tr.CommandAdminBackfillDescription)
+	admin.AddCommand(backfill)
+	quiz := model.NewAutocompleteData("quiz", "<step>", tr.CommandAdminQuizDescription)
+	quiz.AddTextArgument(tr.CommandAdminStepArgument, "<step>", "")
+	admin.AddCommand(quiz)
+	template := model.NewAutocompleteData("template", "[command]", tr.CommandAdminTemplateDescription)
+	template.AddCommand(model.NewAutocompleteData("list", "", tr.CommandAdminTemplateListDescription))
+	template.AddCommand(model.NewAutocompleteData("preview", "<id> [version]", tr.CommandAdminTemplatePreviewDescription))
+	template.AddCommand(model.NewAutocompleteData("history", "<id>", tr.CommandAdminTemplateHistoryDescription))
+	template.AddCommand(model.NewAutocompleteData("edit", "<id>", tr.CommandAdminTemplateEditDescription))
+	template.AddCommand(model.NewAutocompleteData("retire", "<id>", tr.CommandAdminTemplateRetireDescription))
+	template.AddCommand(model.NewAutocompleteData("restore", "<id>", tr.CommandAdminTemplateRestoreDescription))
+	template.AddCommand(model.NewAutocompleteData("rollback", "<id> <version>", tr.CommandAdminTemplateRollbackDescription))
+	template.AddCommand(model.NewAutocompleteData("resend", "<id> [--dry-run]", tr.CommandAdminTemplateResendDescription))
+	admin.AddCommand(template)
+	autocomplete.AddCommand(admin)
+
+	return p.API.RegisterCommand(&model.Command{
+		Trigger:          commandTrigger,
+		DisplayName:      botDisplayName,
+		Description:      tr.CommandDescription,
+		AutoComplete:     true,
+		AutoCompleteDesc: tr.CommandDescription,
+		AutoCompleteHint: "[status|show|signature|manager|buddy|language|admin|help]",
+		AutocompleteData: autocomplete,
+	})
+}
+
+// ExecuteCommand handles the /onboarding slash command.
+func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+	fields := strings.Fields(args.Command)
+	if len(fields) == 0 || fields[0] != "/"+commandTrigger {
+		return &model.CommandResponse{}, nil
+	}
+
+	subcommand := "help"
+	if len(fields) > 1 {
+		subcommand = fields[1]
+	}
+
+	tr := p.translationsForUser(args.UserId)
+
+	switch subcommand {
+	case "status":
+		return p.executeStatusCommand(args)
+	case "show":
+		return p.executeShowCommand(args)
+	case "signature":
+		return p.executeSignatureCommand(args, fields[2:])
+	case contactRoleManager, contactRoleBuddy:
+		return p.executeContactCommand(args, subcommand, fields[2:])
+	case "language":
+		return p.executeLanguageCommand(args, fields[2:])
+	case "admin":
+		return p.executeAdminCommand(args, fields[2:])
+	case "help":
+		return ephemeralResponse(tr.CommandHelp), nil
+	default:
+		return ephemeralResponse(fmt.Sprintf(tr.CommandUnknown, subcommand) + "\n\n" + tr.CommandHelp), nil
+	}
+}
+
+// executeSignatureCommand opens the signature dialog, or with "regenerate"
+// rebuilds the user's last signature with the current template
+func (p *Plugin) executeSignatureCommand(args *model.CommandArgs, fields []string) (*model.CommandResponse, *model.AppError) {
+	language := p.languageForUser(args.UserId)
+	tr := p.translationsFor(language)
+
+	if len(fields) == 0 {
+		if err := p.openSignatureDialog(args.UserId, args.TriggerId, nil); err != nil {
+			p.API.LogError("failed to open dialog", "user_id", args.UserId, "err", err.Error())
+			return ephemeralResponse(tr.ErrorGeneral), nil
+		}
+		return &model.CommandResponse{}, nil
+	}
+	if fields[0] != "regenerate" || len(fields) > 1 {
+		return ephemeralResponse(fmt.Sprintf(tr.AdminUsage, "/onboarding signature [regenerate]")), nil
+	}
+
+	err := p.regenerateSignature(args.UserId, "")
+	switch {
+	case errors.Is(err, errSignatureProfileNotFound):
+		return ephemeralResponse(tr.SignatureRegenerateNoProfile), nil
+	case errors.Is(err, errTemplateNotFound):
+		return ephemeralResponse(tr.SignatureRegenerateProjectUnavailable), nil
+	case err != nil:
+		p.API.LogError("failed to regenerate signature", "user_id", args.UserId, "err", err.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+	return ephemeralResponse(tr.SignatureRegenerated), nil
+}
+
+func (p *Plugin) executeStatusCommand(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+	tr := p.translationsForUser(args.UserId)
+
+	state, err := p.loadState(args.UserId)
+	if err != nil {
+		p.API.LogError("failed to load onboarding state", "user_id", args.UserId, "err", err.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+	if state == nil {
+		return ephemeralResponse(tr.CommandStatusNotStarted), nil
+	}
+
+	return ephemeralResponse(p.buildStatusText(state)), nil
+}
+
+// buildStatusText summarizes a user's checklist progress as Markdown
+func (p *Plugin) buildStatusText(state *OnboardingState) string {
+	language := p.languageForState(state)
+	tr := p.translationsFor(language)
+
+	steps := p.stepsForState(state)
+	completed := 0
+	var lines []string
+	for i, step := range steps {
+		done := state.CompletedSteps[step.ID]
+		if done {
+			completed++
+		}
+		lines = append(lines, "- "+checkbox(done)+fmt.Sprintf(tr.StepTitleFormat, i+1, step.Title.Get(language)))
+	}
+
+	text := fmt.Sprintf(tr.CommandStatusHeader.Select(len(steps)), completed, len(steps)) + "\n" + strings.Join(lines, "\n")
+
+	for _, contact := range []struct{ label, userID string }{
+		{tr.StatusManager, state.ManagerID},
+		{tr.StatusBuddy, state.BuddyID},
+	} {
+		if contact.userID == "" {
+			continue
+		}
+		if user, appErr := p.API.GetUser(contact.userID); appErr == nil {
+			text += fmt.Sprintf("\n%s: @%s", contact.label, user.Username)
+		}
+	}
+
+	return text
+}
+
+func (p *Plugin) executeShowCommand(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+	tr := p.translationsForUser(args.UserId)
+
+	user, appErr := p.API.GetUser(args.UserId)
+	if appErr != nil {
+		p.API.LogError("failed to get user", "user_id", args.UserId, "err", appErr.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+
+	state, err := p.loadState(user.Id)
+	if err != nil {
+		p.API.LogError("failed to load onboarding state", "user_id", user.Id, "err", err.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+
+	if state == nil {
+		err = p.startOnboardingForUser(user, "")
+	} else {
+		_, err = p.postChecklist(user, state)
+	}
+	if err != nil {
+		p.API.LogError("failed to post checklist", "user_id", user.Id, "err", err.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+
+	return ephemeralResponse(tr.CommandChecklistPosted), nil
+}
+
+// executeLanguageCommand sets or clears the caller's language override.
+// "auto" goes back to following the Mattermost locale.
+func (p *Plugin) executeLanguageCommand(args *model.CommandArgs, fields []string) (*model.CommandResponse, *model.AppError) {
+	tr := p.translationsForUser(args.UserId)
+	if len(fields) != 1 {
+		return ephemeralResponse(fmt.Sprintf(tr.AdminUsage, "/onboarding language <code>|auto")), nil
+	}
+
+	language := strings.ToLower(fields[0])
+	if language != "auto" && !p.isSupportedLanguage(language) {
+		var codes []string
+		for _, code := range p.supportedLanguages() {
+			codes = append(codes, "`"+code+"`")
+		}
+		return ephemeralResponse(fmt.Sprintf(tr.LanguageUnsupported, fields[0], strings.Join(codes, ", "))), nil
+	}
+
+	user, appErr := p.API.GetUser(args.UserId)
+	if appErr != nil {
+		p.API.LogError("failed to get user", "user_id", args.UserId, "err", appErr.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+	state, err := p.loadState(user.Id)
+	if err != nil {
+		p.API.LogError("failed to load onboarding state", "user_id", user.Id, "err", err.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+	if state == nil {
+		return ephemeralResponse(tr.CommandStatusNotStarted), nil
+	}
+
+	if language == "auto" {
+		language = ""
+	}
+	if err := p.updateState(state, func(s *OnboardingState) { s.Language = language }); err != nil {
+		p.API.LogError("failed to save onboarding state", "user_id", user.Id, "err", err.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+	p.refreshChecklistPost(user, state)
+
+	// Confirm in the language that now applies
+	tr = p.translationsFor(p.resolveLanguage(user, state))
+	if language == "" {
+		return ephemeralResponse(tr.LanguageReset), nil
+	}
+	return ephemeralResponse(tr.LanguageSet), nil
+}
+
+func ephemeralResponse(text string) *model.CommandResponse {
+	return &model.CommandResponse{
+		ResponseType: model.CommandResponseTypeEphemeral,
+		Text:         text,
+	}
+}
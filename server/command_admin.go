@@ -0,0 +1,367 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// adminTarget is a user an admin command acts on
+type adminTarget struct {
+	user  *model.User
+	state *OnboardingState
+}
+
+// executeAdminCommand handles /onboarding admin <subcommand> ...
+func (p *Plugin) executeAdminCommand(args *model.CommandArgs, fields []string) (*model.CommandResponse, *model.AppError) {
+	tr := p.translationsForUser(args.UserId)
+
+	if !p.isOnboardingAdmin(args.UserId, args.TeamId) {
+		return ephemeralResponse(tr.AdminPermissionDenied), nil
+	}
+
+	if len(fields) == 0 {
+		return ephemeralResponse(tr.CommandAdminHelp), nil
+	}
+
+	switch fields[0] {
+	case "start":
+		return p.executeAdminStart(args, fields[1:])
+	case "reset":
+		return p.executeAdminReset(args, fields[1:])
+	case "complete":
+		return p.executeAdminSetStep(args, fields[1:], true)
+	case "uncomplete":
+		return p.executeAdminSetStep(args, fields[1:], false)
+	case "list":
+		return p.executeAdminList(args, fields[1:])
+	case "history":
+		return p.executeAdminHistory(args, fields[1:])
+	case "track":
+		return p.executeAdminTrack(args, fields[1:])
+	case contactRoleManager, contactRoleBuddy:
+		return p.executeAdminContact(args, fields[0], fields[1:])
+	case "backfill":
+		return p.executeAdminBackfill(args, fields[1:])
+	case "template":
+		return p.executeAdminTemplate(args, fields[1:])
+	case "quiz":
+		return p.executeAdminQuiz(args, fields[1:])
+	default:
+		return ephemeralResponse(fmt.Sprintf(tr.CommandUnknown, fields[0]) + "\n\n" + tr.CommandAdminHelp), nil
+	}
+}
+
+// isOnboardingAdmin reports whether the user may manage other users' onboarding.
+// System admins manage everyone; team admins manage members of the current team.
+func (p *Plugin) isOnboardingAdmin(userID, teamID string) bool {
+	if p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+		return true
+	}
+	return teamID != "" && p.API.HasPermissionToTeam(userID, teamID, model.PermissionManageTeam)
+}
+
+// canManageUser checks that a team admin only touches members of their team
+func (p *Plugin) canManageUser(actorID, teamID, targetID string) bool {
+	if p.API.HasPermissionTo(actorID, model.PermissionManageSystem) {
+		return true
+	}
+	if teamID == "" || !p.API.HasPermissionToTeam(actorID, teamID, model.PermissionManageTeam) {
+		return false
+	}
+	member, appErr := p.API.GetTeamMember(teamID, targetID)
+	return appErr == nil && member != nil && member.DeleteAt == 0
+}
+
+// commandTeamFor returns the team the command runs in if the user is a member
+// of it, so onboarding started by an admin is scoped like a team join
+func (p *Plugin) commandTeamFor(args *model.CommandArgs, userID string) string {
+	if args.TeamId == "" {
+		return ""
+	}
+	member, appErr := p.API.GetTeamMember(args.TeamId, userID)
+	if appErr != nil || member == nil || member.DeleteAt != 0 {
+		return ""
+	}
+	return args.TeamId
+}
+
+// resolveAdminTarget looks up the @user argument and checks permissions.
+// On failure it returns the ephemeral message to show.
+func (p *Plugin) resolveAdminTarget(args *model.CommandArgs, username string) (*adminTarget, string) {
+	tr := p.translationsForUser(args.UserId)
+
+	username = strings.TrimPrefix(username, "@")
+	user, appErr := p.API.GetUserByUsername(username)
+	if appErr != nil || user == nil {
+		return nil, fmt.Sprintf(tr.AdminUserNotFound, username)
+	}
+	if !p.canManageUser(args.UserId, args.TeamId, user.Id) {
+		return nil, tr.AdminPermissionDenied
+	}
+
+	state, err := p.loadState(user.Id)
+	if err != nil {
+		p.API.LogError("failed to load onboarding state", "user_id", user.Id, "err", err.Error())
+		return nil, tr.ErrorGeneral
+	}
+
+	return &adminTarget{user: user, state: state}, ""
+}
+
+func (p *Plugin) executeAdminStart(args *model.CommandArgs, fields []string) (*model.CommandResponse, *model.AppError) {
+	tr := p.translationsForUser(args.UserId)
+	if len(fields) != 1 {
+		return ephemeralResponse(fmt.Sprintf(tr.AdminUsage, "/onboarding admin start @user")), nil
+	}
+
+	target, msg := p.resolveAdminTarget(args, fields[0])
+	if target == nil {
+		return ephemeralResponse(msg), nil
+	}
+	if target.state != nil {
+		return ephemeralResponse(fmt.Sprintf(tr.AdminOnboardingAlreadyStarted, target.user.Username, target.user.Username)), nil
+	}
+
+	if err := p.startOnboardingForUser(target.user, p.commandTeamFor(args, target.user.Id)); err != nil {
+		p.API.LogError("Failed to start onboarding", "user_id", target.user.Id, "err", err.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+
+	return ephemeralResponse(fmt.Sprintf(tr.AdminOnboardingStarted, target.user.Username)), nil
+}
+
+func (p *Plugin) executeAdminReset(args *model.CommandArgs, fields []string) (*model.CommandResponse, *model.AppError) {
+	tr := p.translationsForUser(args.UserId)
+	if len(fields) != 1 {
+		return ephemeralResponse(fmt.Sprintf(tr.AdminUsage, "/onboarding admin reset @user")), nil
+	}
+
+	target, msg := p.resolveAdminTarget(args, fields[0])
+	if target == nil {
+		return ephemeralResponse(msg), nil
+	}
+
+	if err := p.deleteState(target.user.Id); err != nil {
+		p.API.LogError("failed to delete onboarding state", "user_id", target.user.Id, "err", err.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+	if err := p.appendStepEvent(target.user.Id, StepEvent{
+		Action:  eventActionReset,
+		ActorID: args.UserId,
+		Source:  eventSourceCommand,
+	}); err != nil {
+		p.API.LogWarn("failed to record onboarding reset", "user_id", target.user.Id, "err", err.Error())
+	}
+	if err := p.startOnboardingForUser(target.user, p.commandTeamFor(args, target.user.Id)); err != nil {
+		p.API.LogError("Failed to start onboarding", "user_id", target.user.Id, "err", err.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+
+	return ephemeralResponse(fmt.Sprintf(tr.AdminOnboardingReset, target.user.Username)), nil
+}
+
+func (p *Plugin) executeAdminSetStep(args *model.CommandArgs, fields []string, completed bool) (*model.CommandResponse, *model.AppError) {
+	tr := p.translationsForUser(args.UserId)
+	if len(fields) != 2 {
+		usage := "/onboarding admin complete @user <step>"
+		if !completed {
+			usage = "/onboarding admin uncomplete @user <step>"
+		}
+		return ephemeralResponse(fmt.Sprintf(tr.AdminUsage, usage)), nil
+	}
+
+	target, msg := p.resolveAdminTarget(args, fields[0])
+	if target == nil {
+		return ephemeralResponse(msg), nil
+	}
+	if target.state == nil {
+		return ephemeralResponse(fmt.Sprintf(tr.AdminNotStarted, target.user.Username, target.user.Username)), nil
+	}
+
+	steps := p.stepsForState(target.state)
+	stepDef, ok := findStep(steps, fields[1])
+	if !ok {
+		ids := make([]string, 0, len(steps))
+		for _, step := range steps {
+			ids = append(ids, "`"+step.ID+"`")
+		}
+		return ephemeralResponse(fmt.Sprintf(tr.AdminUnknownStep, fields[1], strings.Join(ids, ", "))), nil
+	}
+
+	if err := p.setStepCompleted(target.state, stepDef.ID, completed, args.UserId, eventSourceCommand); err != nil {
+		p.API.LogError("failed to save onboarding state", "user_id", target.user.Id, "err", err.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+	p.refreshChecklistPost(target.user, target.state)
+
+	title := stepDef.Title.Get(p.languageForUser(args.UserId))
+	if completed {
+		return ephemeralResponse(fmt.Sprintf(tr.AdminStepCompleted, title, target.user.Username)), nil
+	}
+	return ephemeralResponse(fmt.Sprintf(tr.AdminStepUncompleted, title, target.user.Username)), nil
+}
+
+func (p *Plugin) executeAdminList(args *model.CommandArgs, fields []string) (*model.CommandResponse, *model.AppError) {
+	tr := p.translationsForUser(args.UserId)
+
+	onlyIncomplete := false
+	for _, field := range fields {
+		if field != "--incomplete" {
+			return ephemeralResponse(fmt.Sprintf(tr.AdminUsage, "/onboarding admin list [--incomplete]")), nil
+		}
+		onlyIncomplete = true
+	}
+
+	userIDs, err := p.listStateUserIDs()
+	if err != nil {
+		p.API.LogError("failed to list onboarding states", "err", err.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+
+	var lines []string
+	for _, userID := range userIDs {
+		if !p.canManageUser(args.UserId, args.TeamId, userID) {
+			continue
+		}
+
+		state, err := p.loadState(userID)
+		if err != nil || state == nil {
+			continue
+		}
+
+		steps := p.stepsForState(state)
+		completed := countCompleted(state, steps)
+		if onlyIncomplete && completed == len(steps) {
+			continue
+		}
+
+		username := userID
+		if user, appErr := p.API.GetUser(userID); appErr == nil {
+			username = user.Username
+		}
+		lines = append(lines, fmt.Sprintf(tr.AdminListEntry, username, completed, len(steps), state.LastUpdated.Format("2006-01-02")))
+	}
+
+	if len(lines) == 0 {
+		return ephemeralResponse(tr.AdminListEmpty), nil
+	}
+	sort.Strings(lines)
+
+	return ephemeralResponse(fmt.Sprintf(tr.AdminListHeader.Select(len(lines)), len(lines)) + "\n" + strings.Join(lines, "\n")), nil
+}
+
+func (p *Plugin) executeAdminHistory(args *model.CommandArgs, fields []string) (*model.CommandResponse, *model.AppError) {
+	tr := p.translationsForUser(args.UserId)
+	if len(fields) != 1 {
+		return ephemeralResponse(fmt.Sprintf(tr.AdminUsage, "/onboarding admin history @user")), nil
+	}
+
+	target, msg := p.resolveAdminTarget(args, fields[0])
+	if target == nil {
+		return ephemeralResponse(msg), nil
+	}
+
+	events, err := p.loadStepEvents(target.user.Id)
+	if err != nil {
+		p.API.LogError("failed to load onboarding history", "user_id", target.user.Id, "err", err.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+	if len(events) == 0 {
+		return ephemeralResponse(fmt.Sprintf(tr.AdminHistoryEmpty, target.user.Username)), nil
+	}
+
+	lines := make([]string, 0, len(events))
+	for _, event := range events {
+		actor := event.ActorID
+		if user, appErr := p.API.GetUser(event.ActorID); appErr == nil {
+			actor = "@" + user.Username
+		}
+		step := event.Step
+		if step == "" {
+			step = "-"
+		}
+		source := event.Source
+		if event.Forced {
+			source += " (forced)"
+		}
+		lines = append(lines, fmt.Sprintf("| %s | `%s` | %s | %s | %s |",
+			event.Timestamp.Format("2006-01-02 15:04 MST"), step, event.Action, actor, source))
+	}
+
+	return ephemeralResponse(fmt.Sprintf(tr.AdminHistoryHeader, target.user.Username) + "\n" +
+		tr.AdminHistoryTableHeader + "\n|---|---|---|---|---|\n" + strings.Join(lines, "\n")), nil
+}
+
+// executeAdminTrack assigns a track by hand. The track is kept when the user
+// joins further teams.
+func (p *Plugin) executeAdminTrack(args *model.CommandArgs, fields []string) (*model.CommandResponse, *model.AppError) {
+	tr := p.translationsForUser(args.UserId)
+	if len(fields) != 2 {
+		return ephemeralResponse(fmt.Sprintf(tr.AdminUsage, "/onboarding admin track @user <track>")), nil
+	}
+
+	target, msg := p.resolveAdminTarget(args, fields[0])
+	if target == nil {
+		return ephemeralResponse(msg), nil
+	}
+	if target.state == nil {
+		return ephemeralResponse(fmt.Sprintf(tr.AdminNotStarted, target.user.Username, target.user.Username)), nil
+	}
+
+	tracks := p.getTracks()
+	if len(tracks) == 0 {
+		return ephemeralResponse(tr.AdminNoTracks), nil
+	}
+	track, ok := findTrack(tracks, fields[1])
+	if !ok {
+		ids := make([]string, 0, len(tracks))
+		for _, track := range tracks {
+			ids = append(ids, "`"+track.ID+"`")
+		}
+		return ephemeralResponse(fmt.Sprintf(tr.AdminUnknownTrack, fields[1], strings.Join(ids, ", "))), nil
+	}
+
+	if err := p.updateState(target.state, func(s *OnboardingState) {
+		s.Track = track.ID
+		s.TrackFinal = true
+	}); err != nil {
+		p.API.LogError("failed to save onboarding state", "user_id", target.user.Id, "err", err.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+	p.refreshChecklistPost(target.user, target.state)
+	// Steps of the new track may already pass their checks
+	p.verifyUserSteps(target.state)
+
+	return ephemeralResponse(fmt.Sprintf(tr.AdminTrackAssigned, track.Name.Get(p.languageForUser(args.UserId)), target.user.Username)), nil
+}
+
+func (p *Plugin) executeAdminContact(args *model.CommandArgs, role string, fields []string) (*model.CommandResponse, *model.AppError) {
+	tr := p.translationsForUser(args.UserId)
+	if len(fields) != 2 {
+		return ephemeralResponse(fmt.Sprintf(tr.AdminUsage, "/onboarding admin "+role+" @user @"+role)), nil
+	}
+
+	target, msg := p.resolveAdminTarget(args, fields[0])
+	if target == nil {
+		return ephemeralResponse(msg), nil
+	}
+	if target.state == nil {
+		return ephemeralResponse(fmt.Sprintf(tr.AdminNotStarted, target.user.Username, target.user.Username)), nil
+	}
+
+	return ephemeralResponse(p.assignContactByUsername(args.UserId, target.user, target.state, role, fields[1])), nil
+}
+
+// countCompleted returns how many of the given steps the user has completed
+func countCompleted(state *OnboardingState, steps []StepDefinition) int {
+	completed := 0
+	for _, step := range steps {
+		if state.CompletedSteps[step.ID] {
+			completed++
+		}
+	}
+	return completed
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// Contact roles that can be assigned to a new hire
+const (
+	contactRoleManager = "manager"
+	contactRoleBuddy   = "buddy"
+)
+
+// assignContact stores the manager or buddy on the state and introduces them
+// to the new hire by DM.
+func (p *Plugin) assignContact(newHire *model.User, state *OnboardingState, role string, contact *model.User) error {
+	if role != contactRoleManager && role != contactRoleBuddy {
+		return fmt.Errorf("unknown contact role %q", role)
+	}
+
+	if err := p.updateState(state, func(s *OnboardingState) {
+		if role == contactRoleManager {
+			s.ManagerID = contact.Id
+		} else {
+			s.BuddyID = contact.Id
+		}
+	}); err != nil {
+		return err
+	}
+
+	tr := p.translationsForUser(contact.Id)
+	message := tr.ContactIntroManager
+	if role == contactRoleBuddy {
+		message = tr.ContactIntroBuddy
+	}
+
+	return p.sendBotDM(contact.Id, fmt.Sprintf(message, displayNameOf(contact), displayNameOf(newHire), newHire.Username))
+}
+
+// notifyOnboardingCompleted tells the manager once the new hire finished
+// every step. It only fires the first time the checklist is completed.
+func (p *Plugin) notifyOnboardingCompleted(state *OnboardingState) {
+	if !state.CompletedAt.IsZero() || len(p.remainingSteps(state)) > 0 {
+		return
+	}
+
+	// Only the update that sets CompletedAt sends the notice
+	first := false
+	if err := p.updateState(state, func(s *OnboardingState) {
+		first = s.CompletedAt.IsZero() && len(p.remainingSteps(s)) == 0
+		if first {
+			s.CompletedAt = time.Now().UTC()
+		}
+	}); err != nil {
+		p.API.LogWarn("failed to save onboarding completion", "user_id", state.UserID, "err", err.Error())
+		return
+	}
+	if !first {
+		return
+	}
+
+	if state.ManagerID == "" {
+		return
+	}
+
+	newHire, appErr := p.API.GetUser(state.UserID)
+	if appErr != nil {
+		p.API.LogWarn("failed to get user for completion notice", "user_id", state.UserID, "err", appErr.Error())
+		return
+	}
+
+	tr := p.translationsForUser(state.ManagerID)
+	if err := p.sendBotDM(state.ManagerID, fmt.Sprintf(tr.ContactOnboardingCompleted, displayNameOf(newHire), newHire.Username)); err != nil {
+		p.API.LogWarn("failed to notify manager", "user_id", state.UserID, "manager_id", state.ManagerID, "err", err.Error())
+	}
+}
+
+// escalateIfStalled DMs the manager and buddy when the new hire hasn't made
+// progress for EscalationDays. Each stall is escalated once.
+func (p *Plugin) escalateIfStalled(state *OnboardingState, record *ReminderRecord, now time.Time) (bool, error) {
+	days := p.getPluginIntSetting("EscalationDays", 7)
+	if days <= 0 || (state.ManagerID == "" && state.BuddyID == "") {
+		return false, nil
+	}
+	lastProgress := lastProgressAt(state)
+	if now.Sub(lastProgress) < time.Duration(days)*24*time.Hour || record.EscalatedAt.After(lastProgress) {
+		return false, nil
+	}
+
+	remaining := p.remainingSteps(state)
+	if len(remaining) == 0 {
+		return false, nil
+	}
+
+	newHire, appErr := p.API.GetUser(state.UserID)
+	if appErr != nil {
+		return false, appErr
+	}
+	if newHire.DeleteAt != 0 {
+		return false, nil
+	}
+
+	for _, contactID := range []string{state.ManagerID, state.BuddyID} {
+		if contactID == "" {
+			continue
+		}
+
+		// Each contact reads the escalation in their own language
+		language := p.languageForUser(contactID)
+		tr := p.translationsFor(language)
+
+		lines := make([]string, 0, len(remaining))
+		for _, step := range remaining {
+			lines = append(lines, "- "+checkbox(false)+step.Title.Get(language))
+		}
+		message := fmt.Sprintf(tr.ContactEscalation.Select(days), displayNameOf(newHire), newHire.Username, days) + "\n\n" + strings.Join(lines, "\n")
+
+		if err := p.sendBotDM(contactID, message); err != nil {
+			p.API.LogWarn("failed to send escalation", "user_id", state.UserID, "contact_id", contactID, "err", err.Error())
+		}
+	}
+
+	record.EscalatedAt = now
+	return true, nil
+}
+
+// sendBotDM posts a plain message from the bot into the user's DM
+func (p *Plugin) sendBotDM(userID, message string) error {
+	channel, appErr := p.API.GetDirectChannel(p.botUserID, userID)
+	if appErr != nil {
+		return appErr
+	}
+
+	post := &model.Post{
+		UserId:    p.botUserID,
+		ChannelId: channel.Id,
+		Message:   message,
+	}
+	if _, appErr := p.API.CreatePost(post); appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+// displayNameOf returns the user's full name, or the username if none is set
+func displayNameOf(user *model.User) string {
+	if name := user.GetFullName(); name != "" {
+		return name
+	}
+	return user.Username
+}
+
+// executeContactCommand handles /onboarding manager|buddy @user for the caller
+func (p *Plugin) executeContactCommand(args *model.CommandArgs, role string, fields []string) (*model.CommandResponse, *model.AppError) {
+	tr := p.translationsForUser(args.UserId)
+	if len(fields) != 1 {
+		return ephemeralResponse(fmt.Sprintf(tr.AdminUsage, "/onboarding "+role+" @user")), nil
+	}
+
+	newHire, appErr := p.API.GetUser(args.UserId)
+	if appErr != nil {
+		p.API.LogError("failed to get user", "user_id", args.UserId, "err", appErr.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+	state, err := p.loadState(newHire.Id)
+	if err != nil {
+		p.API.LogError("failed to load onboarding state", "user_id", newHire.Id, "err", err.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+	if state == nil {
+		return ephemeralResponse(tr.CommandStatusNotStarted), nil
+	}
+
+	return ephemeralResponse(p.assignContactByUsername(args.UserId, newHire, state, role, fields[0])), nil
+}
+
+// assignContactByUsername resolves the contact and returns the reply text
+// in the language of the person running the command
+func (p *Plugin) assignContactByUsername(actorID string, newHire *model.User, state *OnboardingState, role, username string) string {
+	tr := p.translationsForUser(actorID)
+
+	username = strings.TrimPrefix(username, "@")
+	contact, appErr := p.API.GetUserByUsername(username)
+	if appErr != nil || contact == nil || contact.DeleteAt != 0 {
+		return fmt.Sprintf(tr.AdminUserNotFound, username)
+	}
+	if contact.IsBot || contact.Id == newHire.Id {
+		return tr.ContactInvalid
+	}
+
+	if err := p.assignContact(newHire, state, role, contact); err != nil {
+		p.API.LogError("failed to assign contact", "user_id", newHire.Id, "role", role, "err", err.Error())
+		return tr.ErrorGeneral
+	}
+
+	if role == contactRoleBuddy {
+		return fmt.Sprintf(tr.ContactBuddyAssigned, contact.Username, newHire.Username)
+	}
+	return fmt.Sprintf(tr.ContactManagerAssigned, contact.Username, newHire.Username)
+}
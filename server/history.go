@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// StepEvent is one entry in a user's append-only onboarding history
+type StepEvent struct {
+	Step    string `json:"step,omitempty"`
+	Action  string `json:"action"`
+	ActorID string `json:"actor_id"`
+	Source  string `json:"source"`
+	// Forced marks API changes that completed a step despite failing checks
+	Forced    bool      `json:"forced,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+const (
+	onboardingEventsKVPrefix = "onboarding:events:"
+
+	eventActionComplete   = "complete"
+	eventActionUncomplete = "uncomplete"
+	eventActionReset      = "reset"
+
+	eventSourceButton  = "button"
+	eventSourceCommand = "command"
+	eventSourceAPI     = "api"
+	// eventSourceVerifier marks steps completed because their checks passed
+	eventSourceVerifier = "verifier"
+	// eventSourceIntroduction marks steps completed by posting an introduction
+	eventSourceIntroduction = "introduction"
+	// eventSourcePolicyUpdate marks steps reopened because a policy the user
+	// accepted got a new version
+	eventSourcePolicyUpdate = "policy_update"
+
+	// appendEventAttempts bounds retries when concurrent writers race on the log
+	appendEventAttempts = 5
+)
+
+// setStepCompleted updates a step on the state, saves it and records the
+// change. Only a failed save is returned as an error.
+func (p *Plugin) setStepCompleted(state *OnboardingState, step string, completed bool, actorID, source string) error {
+	return p.applyStepChange(state, completed, StepEvent{Step: step, ActorID: actorID, Source: source})
+}
+
+// applyStepChange is setStepCompleted for callers that fill in more of the
+// recorded event. The action is set from completed.
+func (p *Plugin) applyStepChange(state *OnboardingState, completed bool, event StepEvent) error {
+	step := event.Step
+	event.Action = eventActionComplete
+	if !completed {
+		event.Action = eventActionUncomplete
+	}
+	if err := p.updateState(state, func(s *OnboardingState) {
+		if s.CompletedSteps == nil {
+			s.CompletedSteps = map[string]bool{}
+		}
+		if completed {
+			s.CompletedSteps[step] = true
+		} else {
+			delete(s.CompletedSteps, step)
+		}
+		s.LastProgressAt = time.Now().UTC()
+	}); err != nil {
+		return err
+	}
+
+	// The change is saved; a lost history entry must not report it as failed
+	if err := p.appendStepEvent(state.UserID, event); err != nil {
+		p.API.LogWarn("failed to record step change", "user_id", state.UserID, "step", step, "err", err.Error())
+	}
+
+	if completed {
+		p.notifyOnboardingCompleted(state)
+	}
+	return nil
+}
+
+// appendStepEvent appends an event to the user's history. The log is only
+// ever appended to, using compare-and-set so concurrent clicks aren't lost.
+func (p *Plugin) appendStepEvent(userID string, event StepEvent) error {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+
+	key := onboardingEventsKVPrefix + userID
+	for attempt := 0; attempt < appendEventAttempts; attempt++ {
+		oldData, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return fmt.Errorf("KVGet: %w", appErr)
+		}
+
+		var events []StepEvent
+		if oldData != nil {
+			if err := json.Unmarshal(oldData, &events); err != nil {
+				return err
+			}
+		}
+		events = append(events, event)
+
+		newData, err := json.Marshal(events)
+		if err != nil {
+			return err
+		}
+
+		ok, appErr := p.API.KVCompareAndSet(key, oldData, newData)
+		if appErr != nil {
+			return fmt.Errorf("KVCompareAndSet: %w", appErr)
+		}
+		if ok {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("append step event: too many concurrent updates")
+}
+
+// loadStepEvents returns the user's history, oldest first
+func (p *Plugin) loadStepEvents(userID string) ([]StepEvent, error) {
+	data, appErr := p.API.KVGet(onboardingEventsKVPrefix + userID)
+	if appErr != nil {
+		return nil, fmt.Errorf("KVGet: %w", appErr)
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var events []StepEvent
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
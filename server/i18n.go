@@ -1,98 +1,342 @@
 package main
 
-// Translations contains all user-facing text for the onboarding plugin
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// Translations contains all user-facing text for the onboarding plugin. The
+// text is loaded from the catalogs in assets/i18n, keyed by field name.
 type Translations struct {
 	// Welcome message
-	WelcomeGreeting string
-	WelcomeIntro    string
-	WelcomeClosing  string
-
-	// Step titles
-	Step1Title string
-	Step2Title string
-	Step3Title string
-	Step4Title string
-	Step5Title string
-	Step6Title string
-
-	// Step descriptions
-	Step1Description string
-	Step2Description string
-	Step3Description string
-	Step4Description string
-	Step5Description string
-	Step6Description string
-
-	// Step links
-	Step1Link string
-	Step2Link string
-	Step3Link string
-	Step4Link string
-	Step5Link string
-	Step6Link string
-
-	// Button labels
-	ButtonMarkAccountsReady  string
-	ButtonMarkProfileComplete string
-	ButtonMarkChannelsJoined string
-	ButtonMarkToolsReady     string
-	ButtonMarkPoliciesReviewed string
-	ButtonMarkIntrosDone     string
-	ButtonGenerateSignature  string
+	WelcomeGreeting       string
+	WelcomeIntro          string
+	WelcomeClosing        string
+	WelcomeChannelMessage string
+	WelcomeAutoJoined     string
+
+	// Checklist
+	StepTitleFormat           string
+	ButtonUncheckStep         string
+	ButtonGenerateSignature   string
+	ButtonComposeIntroduction string
+	ButtonAcknowledgePolicy   string
+
+	// Step checks
+	StepChecksMissing         string
+	CheckMissingProfilePhoto  string
+	CheckMissingFullName      string
+	CheckMissingPosition      string
+	CheckMissingTimezone      string
+	CheckMissingChannelMember string
+	CheckMissingChannelPost   string
+	CheckMissingPolicies      string
+	CheckMissingQuiz          string
+
+	// Auto-join
+	AutoJoinChannelMissing  string
+	AutoJoinChannelArchived string
+
+	// Introduction composer
+	DialogIntroTitle              string
+	DialogIntroIntro              string
+	DialogIntroRole               string
+	DialogIntroRoleHelp           string
+	DialogIntroProjectHelp        string
+	DialogIntroFunFact            string
+	DialogIntroFunFactPlaceholder string
+	DialogIntroFunFactHelp        string
+	DialogIntroPhoto              string
+	DialogIntroPhotoOption        string
+	DialogIntroPhotoHelp          string
+	DialogIntroSubmitButton       string
+	DialogFieldRequired           string
+	IntroPostGreeting             string
+	IntroPostRole                 string
+	IntroPostProject              string
+	IntroPostPronouns             string
+	IntroPostFunFact              string
+	IntroPostAttribution          string
+	IntroductionPosted            string
+
+	// Policies
+	PolicyLine           string
+	PolicyAcceptedOn     string
+	PolicyAcknowledged   string
+	PolicyUpdatedMessage string
+	PolicyUpdateAccepted string
+
+	// Quizzes
+	ButtonStartQuiz    string
+	QuizLine           string
+	QuizAttemptsLeft   string
+	QuizNoAttemptsLeft string
+	QuizPassedOn       string
+	DialogQuizTitle    string
+	DialogQuizAnswer   string
+	DialogQuizNext     string
+	DialogQuizFinish   string
+	QuizPassed         string
+	QuizFailed         string
+	QuizRetryHint      string
+	QuizAlreadyPassed  string
 
 	// Signature dialog
-	DialogSignatureTitle      string
-	DialogSignatureIntro      string
-	DialogFullName            string
-	DialogFullNameHelp        string
-	DialogPosition            string
-	DialogPositionHelp        string
-	DialogPronouns            string
-	DialogPronounsPlaceholder string
-	DialogPronounsHelp        string
-	DialogEmail               string
-	DialogEmailHelp           string
-	DialogProject             string
-	DialogProjectHelp         string
-	DialogWorkNumber          string
-	DialogWorkNumberPlaceholder string
-	DialogWorkNumberHelp      string
-	DialogSubmitButton        string
-
-	// Project names in dialog
-	ProjectEachOne   string
-	ProjectCommunity string
-	ProjectCUZ       string
-	ProjectJugend    string
-	ProjectNAR       string
-	ProjectAfrolution string
+	DialogSignatureTitle            string
+	DialogSignatureIntro            string
+	DialogFullName                  string
+	DialogFullNameHelp              string
+	DialogPosition                  string
+	DialogPositionHelp              string
+	DialogPronouns                  string
+	DialogPronounsPlaceholder       string
+	DialogPronounsHelp              string
+	DialogPronounsCustomOption      string
+	DialogCustomPronouns            string
+	DialogCustomPronounsPlaceholder string
+	DialogCustomPronounsHelp        string
+	DialogCustomPronounsInvalid     string
+	DialogEmail                     string
+	DialogEmailHelp                 string
+	DialogProject                   string
+	DialogProjectHelp               string
+	DialogWorkNumber                string
+	DialogWorkNumberPlaceholder     string
+	DialogWorkNumberHelp            string
+	DialogMobileNumber              string
+	DialogMobileNumberPlaceholder   string
+	DialogMobileNumberHelp          string
+	DialogPhoneNumberInvalid        string
+	DialogPhoneNumberLength         string
+	DialogProjectUnavailable        string
+	DialogTextFile                  string
+	DialogTextFileOption            string
+	DialogTextFileHelp              string
+	DialogSubmitButton              string
 
 	// Success messages
-	SignatureGeneratedTitle   string
-	SignatureGeneratedMessage string
-	SignatureInstructionsTitle string
-	SignatureInstructionsOutlook string
-	SignatureInstructionsThunderbird string
-	StepMarkedComplete        string
-	DialogOpening             string
+	SignatureGeneratedTitle               string
+	SignatureGeneratedMessage             string
+	SignatureInstructionsTitle            string
+	SignatureInstructionsOutlook          string
+	SignatureInstructionsThunderbird      string
+	SignatureInstructionsPlainText        string
+	SignaturePreviewTitle                 string
+	SignaturePreviewHint                  string
+	SignaturePreviewSent                  string
+	SignaturePreviewAlreadySent           string
+	SignaturePreviewReplaced              string
+	ButtonSignatureSend                   string
+	ButtonSignatureEdit                   string
+	SignatureRegenerated                  string
+	SignatureRegenerateNoProfile          string
+	SignatureRegenerateProjectUnavailable string
+	SignatureTemplateUpdated              string
+	StepMarkedComplete                    string
+	StepMarkedIncomplete                  string
+	DialogOpening                         string
+
+	// Slash commands
+	CommandDescription                    string
+	CommandStatusDescription              string
+	CommandShowDescription                string
+	CommandSignatureDescription           string
+	CommandSignatureRegenerateDescription string
+	CommandHelpDescription                string
+	CommandHelp                           string
+	CommandUnknown                        string
+	CommandStatusHeader                   PluralMessage
+	CommandStatusNotStarted               string
+	CommandChecklistPosted                string
+
+	// Admin commands
+	CommandAdminDescription           string
+	CommandAdminStartDescription      string
+	CommandAdminResetDescription      string
+	CommandAdminCompleteDescription   string
+	CommandAdminUncompleteDescription string
+	CommandAdminListDescription       string
+	CommandAdminListIncomplete        string
+	CommandAdminHistoryDescription    string
+	CommandAdminUserArgument          string
+	CommandAdminStepArgument          string
+	CommandAdminHelp                  string
+	AdminPermissionDenied             string
+	AdminUsage                        string
+	AdminUserNotFound                 string
+	AdminOnboardingStarted            string
+	AdminOnboardingAlreadyStarted     string
+	AdminOnboardingReset              string
+	AdminNotStarted                   string
+	AdminUnknownStep                  string
+	AdminStepCompleted                string
+	AdminStepUncompleted              string
+	AdminListHeader                   PluralMessage
+	AdminListEntry                    string
+	AdminHistoryHeader                string
+	AdminHistoryTableHeader           string
+	AdminHistoryEmpty                 string
+	AdminListEmpty                    string
+	CommandAdminTrackDescription      string
+	CommandAdminTrackArgument         string
+	AdminTrackAssigned                string
+	AdminUnknownTrack                 string
+	AdminNoTracks                     string
+
+	// Reminders
+	ReminderMessage PluralMessage
+	ReminderClosing string
+
+	// Manager & buddy
+	CommandManagerDescription      string
+	CommandBuddyDescription        string
+	CommandContactArgument         string
+	CommandAdminManagerDescription string
+	CommandAdminBuddyDescription   string
+	ContactManagerAssigned         string
+	ContactBuddyAssigned           string
+	ContactInvalid                 string
+	ContactIntroManager            string
+	ContactIntroBuddy              string
+	ContactEscalation              PluralMessage
+	ContactOnboardingCompleted     string
+	StatusManager                  string
+	StatusBuddy                    string
+
+	// Backfill
+	CommandAdminBackfillDescription string
+	AdminBackfillStarted            string
+	AdminBackfillSummary            string
+	AdminBackfillDryRunSummary      string
+
+	// Signature templates
+	CommandAdminTemplateDescription         string
+	CommandAdminTemplateListDescription     string
+	CommandAdminTemplatePreviewDescription  string
+	CommandAdminTemplateHistoryDescription  string
+	CommandAdminTemplateRetireDescription   string
+	CommandAdminTemplateRestoreDescription  string
+	CommandAdminTemplateRollbackDescription string
+	AdminTemplateListHeader                 string
+	AdminTemplateListEntry                  string
+	AdminTemplateListEmpty                  string
+	AdminTemplateRetiredMarker              string
+	AdminTemplateHistoryHeader              string
+	AdminTemplateHistoryEntry               string
+	AdminTemplateNotFound                   string
+	AdminTemplateVersionNotFound            string
+	AdminTemplateRetired                    string
+	AdminTemplateRestored                   string
+	AdminTemplateRolledBack                 string
+	AdminTemplatePreview                    string
+	AdminTemplatePreviewSent                string
+	CommandAdminTemplateResendDescription   string
+	AdminTemplateResendStarted              string
+	AdminTemplateResendSummary              string
+	AdminTemplateResendDryRunSummary        string
+	CommandAdminTemplateEditDescription     string
+	AdminTemplateSaved                      string
+	AdminTemplateInvalid                    string
+	DialogTemplateTitle                     string
+	DialogTemplateIntro                     string
+	DialogTemplateNameDE                    string
+	DialogTemplateNameEN                    string
+	DialogTemplateNameHelp                  string
+	DialogTemplateHTML                      string
+	DialogTemplateHTMLHelp                  string
+	DialogTemplateOrganization              string
+	DialogTemplateAddress                   string
+	DialogTemplateTextHelp                  string
+	DialogTemplateSubmit                    string
+
+	// Quiz results
+	CommandAdminQuizDescription string
+	AdminQuizStatsHeader        string
+	AdminQuizStatsTableHeader   string
+	AdminQuizUnknown            string
+	AdminQuizNoAttempts         string
+
+	// Language
+	LanguageName               string
+	CommandLanguageDescription string
+	CommandLanguageAuto        string
+	LanguageSet                string
+	LanguageReset              string
+	LanguageUnsupported        string
 
 	// Error messages
-	ErrorGeneral string
+	ErrorGeneral                     string
+	ErrorChecklistOutdated           string
+	ErrorActionExpired               string
+	ErrorSignaturePreviewOutdated    string
+	ErrorSignatureProjectUnavailable string
+	ErrorSignatureGeneration         string
+	ErrorIntroductionChannelMissing  string
+	ErrorPolicyOutdated              string
+	ErrorQuizUnavailable             string
 }
 
-// getTranslations returns the appropriate translation set based on plugin config
+// getTranslations returns the translation set for the admin-configured
+// language. Use translationsForUser for messages addressed to one person.
 func (p *Plugin) getTranslations() Translations {
+	return p.translationsFor(p.getLanguage())
+}
+
+// getLanguage returns the configured bot language code, used as the fallback
+// when a user's own language isn't available
+func (p *Plugin) getLanguage() string {
 	// Get language from plugin settings (default to German)
 	language := p.getPluginSetting("Language", "de")
+	if !p.isSupportedLanguage(language) {
+		return "de"
+	}
+	return language
+}
 
-	switch language {
-	case "en":
-		return translationsEN
-	case "de":
-		return translationsDE
-	default:
-		return translationsDE // Default to German
+// resolveLanguage picks a user's language: their /onboarding language
+// override, then their Mattermost locale, then the admin setting.
+func (p *Plugin) resolveLanguage(user *model.User, state *OnboardingState) string {
+	if state != nil && p.isSupportedLanguage(state.Language) {
+		return state.Language
+	}
+	if user != nil {
+		// Locales may carry a region, e.g. "pt-BR"
+		locale := strings.ToLower(strings.SplitN(strings.ReplaceAll(user.Locale, "_", "-"), "-", 2)[0])
+		if p.isSupportedLanguage(locale) {
+			return locale
+		}
 	}
+	return p.getLanguage()
+}
+
+// languageForState resolves the language for the owner of an onboarding state
+func (p *Plugin) languageForState(state *OnboardingState) string {
+	user, appErr := p.API.GetUser(state.UserID)
+	if appErr != nil {
+		return p.resolveLanguage(nil, state)
+	}
+	return p.resolveLanguage(user, state)
+}
+
+// languageForUser resolves the language for a user id
+func (p *Plugin) languageForUser(userID string) string {
+	state, err := p.loadState(userID)
+	if err != nil {
+		p.API.LogDebug("failed to load onboarding state for language", "user_id", userID, "err", err.Error())
+	}
+
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		return p.resolveLanguage(nil, state)
+	}
+	return p.resolveLanguage(user, state)
+}
+
+// translationsForUser returns the translation set for a user id
+func (p *Plugin) translationsForUser(userID string) Translations {
+	return p.translationsFor(p.languageForUser(userID))
 }
 
 // getPluginSetting retrieves a plugin configuration setting
@@ -114,3 +358,57 @@ func (p *Plugin) getPluginSetting(key, defaultValue string) string {
 
 	return value
 }
+
+// getPluginBoolSetting retrieves a boolean plugin configuration setting
+func (p *Plugin) getPluginBoolSetting(key string, defaultValue bool) bool {
+	config := p.API.GetConfig()
+	if config == nil || config.PluginSettings.Plugins == nil {
+		return defaultValue
+	}
+
+	pluginConfig, ok := config.PluginSettings.Plugins["com.akinlosotutech.onboardinghelper"]
+	if !ok {
+		return defaultValue
+	}
+
+	switch value := pluginConfig[key].(type) {
+	case bool:
+		return value
+	case string:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return defaultValue
+		}
+		return parsed
+	default:
+		return defaultValue
+	}
+}
+
+// getPluginIntSetting retrieves a numeric plugin configuration setting
+func (p *Plugin) getPluginIntSetting(key string, defaultValue int) int {
+	config := p.API.GetConfig()
+	if config == nil || config.PluginSettings.Plugins == nil {
+		return defaultValue
+	}
+
+	pluginConfig, ok := config.PluginSettings.Plugins["com.akinlosotutech.onboardinghelper"]
+	if !ok {
+		return defaultValue
+	}
+
+	switch value := pluginConfig[key].(type) {
+	case float64:
+		return int(value)
+	case int:
+		return value
+	case string:
+		parsed, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return defaultValue
+		}
+		return parsed
+	default:
+		return defaultValue
+	}
+}
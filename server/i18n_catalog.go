@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// i18nBundleDir holds one <language>.json catalog per language
+	i18nBundleDir = "assets/i18n"
+	// referenceLanguage is the fallback for keys missing from other catalogs
+	referenceLanguage = "en"
+	// i18nOverrideKVPrefix stores admin-uploaded catalogs, keyed by language
+	i18nOverrideKVPrefix = "onboarding:i18n:"
+	// clusterEventReloadTranslations tells other nodes an override changed
+	clusterEventReloadTranslations = "reload_translations"
+)
+
+// languageCodePattern matches the language codes used as catalog names
+var languageCodePattern = regexp.MustCompile(`^[a-z]{2,3}$`)
+
+// messageCatalog maps message IDs (the Translations field names) to text
+type messageCatalog map[string]string
+
+// translationStore holds the translation sets built from the catalogs
+type translationStore struct {
+	sync.RWMutex
+	translations map[string]Translations
+}
+
+var pluralMessageType = reflect.TypeOf(PluralMessage{})
+
+// messageIDs lists the IDs of the plain messages in Translations
+func messageIDs() []string {
+	return translationFieldsOfType(reflect.TypeOf(""))
+}
+
+// pluralMessageIDs lists the IDs of the plural messages in Translations
+func pluralMessageIDs() []string {
+	return translationFieldsOfType(pluralMessageType)
+}
+
+func translationFieldsOfType(fieldType reflect.Type) []string {
+	t := reflect.TypeOf(Translations{})
+	ids := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Type == fieldType {
+			ids = append(ids, t.Field(i).Name)
+		}
+	}
+	return ids
+}
+
+// parseMessageCatalog parses a flat JSON object of message ID -> text
+func parseMessageCatalog(data []byte) (messageCatalog, error) {
+	var catalog messageCatalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("parse message catalog: %w", err)
+	}
+	return catalog, nil
+}
+
+// loadBundleCatalogs reads every catalog shipped in the plugin bundle
+func (p *Plugin) loadBundleCatalogs() (map[string]messageCatalog, error) {
+	bundlePath, appErr := p.API.GetBundlePath()
+	if appErr != nil {
+		return nil, fmt.Errorf("get bundle path: %w", appErr)
+	}
+	return loadCatalogDir(filepath.Join(bundlePath, i18nBundleDir))
+}
+
+// loadCatalogDir reads every <language>.json catalog in a directory
+func loadCatalogDir(dir string) (map[string]messageCatalog, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	catalogs := map[string]messageCatalog{}
+	for _, file := range files {
+		language := strings.ToLower(strings.TrimSuffix(filepath.Base(file), ".json"))
+		if !languageCodePattern.MatchString(language) {
+			return nil, fmt.Errorf("%s: file name is not a language code", filepath.Base(file))
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		catalog, err := parseMessageCatalog(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(file), err)
+		}
+		catalogs[language] = catalog
+	}
+	return catalogs, nil
+}
+
+// loadCatalogOverrides reads every admin-uploaded catalog from the KV store
+func (p *Plugin) loadCatalogOverrides() (map[string]messageCatalog, error) {
+	const perPage = 200
+
+	overrides := map[string]messageCatalog{}
+	for page := 0; ; page++ {
+		keys, appErr := p.API.KVList(page, perPage)
+		if appErr != nil {
+			return nil, fmt.Errorf("KVList: %w", appErr)
+		}
+		for _, key := range keys {
+			if !strings.HasPrefix(key, i18nOverrideKVPrefix) {
+				continue
+			}
+			language := strings.TrimPrefix(key, i18nOverrideKVPrefix)
+			catalog, err := p.loadCatalogOverride(language)
+			if err != nil {
+				p.API.LogError("ignoring invalid translation override", "language", language, "err", err.Error())
+				continue
+			}
+			if catalog != nil {
+				overrides[language] = catalog
+			}
+		}
+		if len(keys) < perPage {
+			return overrides, nil
+		}
+	}
+}
+
+func (p *Plugin) loadCatalogOverride(language string) (messageCatalog, error) {
+	data, appErr := p.API.KVGet(i18nOverrideKVPrefix + language)
+	if appErr != nil {
+		return nil, fmt.Errorf("KVGet: %w", appErr)
+	}
+	if data == nil {
+		return nil, nil
+	}
+	return parseMessageCatalog(data)
+}
+
+func (p *Plugin) saveCatalogOverride(language string, catalog messageCatalog) error {
+	data, err := json.Marshal(catalog)
+	if err != nil {
+		return err
+	}
+	if appErr := p.API.KVSet(i18nOverrideKVPrefix+language, data); appErr != nil {
+		return fmt.Errorf("KVSet: %w", appErr)
+	}
+	return nil
+}
+
+func (p *Plugin) deleteCatalogOverride(language string) error {
+	if appErr := p.API.KVDelete(i18nOverrideKVPrefix + language); appErr != nil {
+		return fmt.Errorf("KVDelete: %w", appErr)
+	}
+	return nil
+}
+
+// loadCatalogs merges the bundled catalogs with the admin overrides. Override
+// keys replace bundled ones, and an override may add a language of its own.
+func (p *Plugin) loadCatalogs() (map[string]messageCatalog, error) {
+	catalogs, err := p.loadBundleCatalogs()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := catalogs[referenceLanguage]; !ok {
+		return nil, fmt.Errorf("reference catalog %s/%s.json not found in plugin bundle", i18nBundleDir, referenceLanguage)
+	}
+
+	overrides, err := p.loadCatalogOverrides()
+	if err != nil {
+		return nil, err
+	}
+	for language, override := range overrides {
+		merged := messageCatalog{}
+		for id, text := range catalogs[language] {
+			merged[id] = text
+		}
+		for id, text := range override {
+			merged[id] = text
+		}
+		catalogs[language] = merged
+	}
+
+	return catalogs, nil
+}
+
+// reloadTranslations rebuilds every translation set from the catalogs and
+// logs the problems validateCatalogs finds.
+func (p *Plugin) reloadTranslations() error {
+	catalogs, err := p.loadCatalogs()
+	if err != nil {
+		return err
+	}
+
+	// Report problems, but still load what is usable: bad messages fall
+	// back to English
+	for _, issue := range validateCatalogs(catalogs, referenceLanguage) {
+		if issue.Language == referenceLanguage || issue.Kind == catalogIssueFormat {
+			p.API.LogError("Translation catalog problem", "language", issue.Language, "key", issue.Key, "problem", issue.Kind, "detail", issue.Detail)
+		} else {
+			p.API.LogWarn("Translation catalog problem", "language", issue.Language, "key", issue.Key, "problem", issue.Kind, "detail", issue.Detail)
+		}
+	}
+
+	reference := catalogs[referenceLanguage]
+	translations := make(map[string]Translations, len(catalogs))
+	for language, catalog := range catalogs {
+		translations[language] = buildTranslations(language, catalog, reference)
+	}
+
+	p.translations.Lock()
+	p.translations.translations = translations
+	p.translations.Unlock()
+	return nil
+}
+
+// buildTranslations fills a translation set from a catalog. Messages that
+// are missing or whose format verbs don't match the reference catalog are
+// taken from the reference catalog instead.
+func buildTranslations(language string, catalog, reference messageCatalog) Translations {
+	var tr Translations
+	value := reflect.ValueOf(&tr).Elem()
+
+	for _, id := range messageIDs() {
+		text, ok := catalog[id]
+		if !ok || !sameFormatVerbs(text, reference[id]) {
+			if text, ok = reference[id]; !ok {
+				text = id
+			}
+		}
+		value.FieldByName(id).SetString(text)
+	}
+
+	for _, id := range pluralMessageIDs() {
+		message := buildPluralMessage(language, id, catalog, reference)
+		if _, ok := message.forms[pluralOther]; !ok {
+			message = buildPluralMessage(referenceLanguage, id, reference, reference)
+		}
+		if _, ok := message.forms[pluralOther]; !ok {
+			message.forms[pluralOther] = id
+		}
+		value.FieldByName(id).Set(reflect.ValueOf(message))
+	}
+
+	return tr
+}
+
+// buildPluralMessage collects the plural forms a language uses from a catalog
+func buildPluralMessage(language, id string, catalog, reference messageCatalog) PluralMessage {
+	message := PluralMessage{language: language, forms: map[string]string{}}
+	referenceText := reference[pluralKey(id, pluralOther)]
+	for _, category := range pluralRuleFor(language).categories {
+		if text, ok := catalog[pluralKey(id, category)]; ok && sameFormatVerbs(text, referenceText) {
+			message.forms[category] = text
+		}
+	}
+	return message
+}
+
+// translationsFor returns the translation set for a language code, or the
+// reference language if there is no catalog for it
+func (p *Plugin) translationsFor(language string) Translations {
+	p.translations.RLock()
+	defer p.translations.RUnlock()
+
+	if tr, ok := p.translations.translations[language]; ok {
+		return tr
+	}
+	return p.translations.translations[referenceLanguage]
+}
+
+// isSupportedLanguage reports whether a catalog exists for the language
+func (p *Plugin) isSupportedLanguage(language string) bool {
+	p.translations.RLock()
+	defer p.translations.RUnlock()
+
+	_, ok := p.translations.translations[language]
+	return ok
+}
+
+// supportedLanguages lists the languages with a catalog, sorted
+func (p *Plugin) supportedLanguages() []string {
+	p.translations.RLock()
+	defer p.translations.RUnlock()
+
+	languages := make([]string, 0, len(p.translations.translations))
+	for language := range p.translations.translations {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// publishTranslationsChanged reloads the catalogs on this node and asks the
+// other cluster nodes to do the same
+func (p *Plugin) publishTranslationsChanged() error {
+	if err := p.reloadTranslations(); err != nil {
+		return err
+	}
+	if err := p.registerCommands(); err != nil {
+		p.API.LogWarn("failed to re-register commands", "err", err.Error())
+	}
+
+	event := model.PluginClusterEvent{Id: clusterEventReloadTranslations}
+	if appErr := p.API.PublishPluginClusterEvent(event, model.PluginClusterEventSendOptions{
+		SendType: model.PluginClusterEventSendTypeReliable,
+	}); appErr != nil {
+		return fmt.Errorf("PublishPluginClusterEvent: %w", appErr)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of catalog problems reported by validateCatalogs
+const (
+	catalogIssueMissing = "missing"
+	catalogIssueExtra   = "extra"
+	catalogIssueFormat  = "format"
+)
+
+// catalogIssue is one problem found in a translation catalog
+type catalogIssue struct {
+	Language string `json:"language"`
+	Key      string `json:"key"`
+	Kind     string `json:"kind"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+func (i catalogIssue) String() string {
+	if i.Detail == "" {
+		return fmt.Sprintf("%s: %s key %s", i.Language, i.Kind, i.Key)
+	}
+	return fmt.Sprintf("%s: %s key %s (%s)", i.Language, i.Kind, i.Key, i.Detail)
+}
+
+// formatVerbPattern matches a fmt verb with optional argument index, flags,
+// width and precision, e.g. %s, %d, %[2]s, %-5.2f or %%
+var formatVerbPattern = regexp.MustCompile(`%(\[(\d+)\])?[-+# 0]*\d*(\.\d+)?([a-zA-Z%])`)
+
+// formatVerbs lists the verbs of a format string ordered by the argument
+// they consume, so translations may reorder arguments with %[n]s.
+func formatVerbs(format string) []string {
+	type verb struct {
+		arg  int
+		name string
+	}
+
+	var verbs []verb
+	next := 1
+	for _, match := range formatVerbPattern.FindAllStringSubmatch(format, -1) {
+		if match[4] == "%" {
+			continue
+		}
+		arg := next
+		if match[2] != "" {
+			arg, _ = strconv.Atoi(match[2])
+		}
+		next = arg + 1
+		verbs = append(verbs, verb{arg: arg, name: match[4]})
+	}
+
+	sort.SliceStable(verbs, func(i, j int) bool { return verbs[i].arg < verbs[j].arg })
+	names := make([]string, 0, len(verbs))
+	for _, v := range verbs {
+		names = append(names, fmt.Sprintf("%d:%%%s", v.arg, v.name))
+	}
+	return names
+}
+
+// sameFormatVerbs reports whether two format strings consume the same
+// arguments with the same verbs
+func sameFormatVerbs(a, b string) bool {
+	return strings.Join(formatVerbs(a), " ") == strings.Join(formatVerbs(b), " ")
+}
+
+// expectedCatalogKeys returns the keys a catalog for the language should
+// contain: every plain message ID plus one key per plural category the
+// language uses. The value is the reference key holding the source text.
+func expectedCatalogKeys(language string) map[string]string {
+	keys := map[string]string{}
+	for _, id := range messageIDs() {
+		keys[id] = id
+	}
+	for _, id := range pluralMessageIDs() {
+		for _, category := range pluralRuleFor(language).categories {
+			keys[pluralKey(id, category)] = pluralKey(id, pluralOther)
+		}
+	}
+	return keys
+}
+
+// validateCatalogs compares every catalog with the reference catalog and
+// reports missing keys, extra keys and format verb mismatches. It has no
+// dependency on the plugin API, so it works on catalogs read with
+// loadCatalogDir as well as on the merged catalogs at activation.
+func validateCatalogs(catalogs map[string]messageCatalog, baseLanguage string) []catalogIssue {
+	reference := catalogs[baseLanguage]
+
+	languages := make([]string, 0, len(catalogs))
+	for language := range catalogs {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+
+	var issues []catalogIssue
+	for _, language := range languages {
+		issues = append(issues, validateCatalog(language, catalogs[language], reference)...)
+	}
+	return issues
+}
+
+// validateCatalog checks one catalog against the reference catalog
+func validateCatalog(language string, catalog, reference messageCatalog) []catalogIssue {
+	expected := expectedCatalogKeys(language)
+
+	keys := make([]string, 0, len(expected)+len(catalog))
+	for key := range expected {
+		keys = append(keys, key)
+	}
+	for key := range catalog {
+		if _, ok := expected[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var issues []catalogIssue
+	for _, key := range keys {
+		referenceKey, isExpected := expected[key]
+		text, inCatalog := catalog[key]
+
+		switch {
+		case !isExpected:
+			issues = append(issues, catalogIssue{Language: language, Key: key, Kind: catalogIssueExtra})
+		case !inCatalog:
+			issues = append(issues, catalogIssue{Language: language, Key: key, Kind: catalogIssueMissing})
+		default:
+			referenceText, ok := reference[referenceKey]
+			if !ok || sameFormatVerbs(text, referenceText) {
+				continue
+			}
+			issues = append(issues, catalogIssue{
+				Language: language,
+				Key:      key,
+				Kind:     catalogIssueFormat,
+				Detail:   fmt.Sprintf("has %s, expected %s", describeVerbs(text), describeVerbs(referenceText)),
+			})
+		}
+	}
+	return issues
+}
+
+func describeVerbs(format string) string {
+	verbs := formatVerbs(format)
+	if len(verbs) == 0 {
+		return "no verbs"
+	}
+	return "[" + strings.Join(verbs, " ") + "]"
+}
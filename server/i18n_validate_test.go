@@ -0,0 +1,71 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// requireValidCatalogs fails the test for every missing or extra key, plural
+// category or format verb mismatch in the catalogs of dir
+func requireValidCatalogs(t *testing.T, dir string) {
+	t.Helper()
+
+	catalogs, err := loadCatalogDir(dir)
+	if err != nil {
+		t.Fatalf("load catalogs from %s: %v", dir, err)
+	}
+	if _, ok := catalogs[referenceLanguage]; !ok {
+		t.Fatalf("reference catalog %s.json not found in %s", referenceLanguage, dir)
+	}
+
+	for _, issue := range validateCatalogs(catalogs, referenceLanguage) {
+		t.Error(issue.String())
+	}
+}
+
+func TestBundledCatalogs(t *testing.T) {
+	requireValidCatalogs(t, filepath.Join("..", i18nBundleDir))
+}
+
+func TestValidateCatalogsReportsProblems(t *testing.T) {
+	reference := messageCatalog{}
+	for key := range expectedCatalogKeys(referenceLanguage) {
+		reference[key] = "text"
+	}
+	reference["AdminUserNotFound"] = "User `%s` not found."
+
+	broken := messageCatalog{}
+	for key, text := range reference {
+		broken[key] = text
+	}
+	delete(broken, "ErrorGeneral")
+	delete(broken, pluralKey("ReminderMessage", pluralOne))
+	broken["NoSuchMessage"] = "text"
+	broken["AdminUserNotFound"] = "Benutzer `%d` nicht gefunden."
+
+	issues := validateCatalogs(map[string]messageCatalog{
+		referenceLanguage: reference,
+		"de":              broken,
+	}, referenceLanguage)
+
+	want := map[string]string{
+		"ErrorGeneral":                          catalogIssueMissing,
+		pluralKey("ReminderMessage", pluralOne): catalogIssueMissing,
+		"NoSuchMessage":                         catalogIssueExtra,
+		"AdminUserNotFound":                     catalogIssueFormat,
+	}
+	for _, issue := range issues {
+		if issue.Language != "de" {
+			t.Errorf("unexpected issue in reference catalog: %s", issue)
+			continue
+		}
+		if want[issue.Key] != issue.Kind {
+			t.Errorf("unexpected issue: %s", issue)
+			continue
+		}
+		delete(want, issue.Key)
+	}
+	for key, kind := range want {
+		t.Errorf("expected %s issue for key %s", kind, key)
+	}
+}
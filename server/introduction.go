@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// Values of the IntroductionPostAs setting
+	introductionPostAsUser = "user"
+	introductionPostAsBot  = "bot"
+
+	maxFunFactLength = 500
+)
+
+// channelMentionEscaper keeps introductions from notifying whole channels
+var channelMentionEscaper = strings.NewReplacer("@channel", "`@channel`", "@all", "`@all`", "@here", "`@here`")
+
+// introduction is a submitted introduction dialog
+type introduction struct {
+	Role           string
+	Project        string
+	Pronouns       string
+	CustomPronouns string
+	FunFact        string
+	IncludePhoto   bool
+}
+
+// introductionChannelName returns the channel introductions are posted to
+func (p *Plugin) introductionChannelName() string {
+	return strings.TrimPrefix(strings.TrimSpace(p.getPluginSetting("IntroductionChannel", "introductions")), "~")
+}
+
+// handleIntroductionDialog opens the introduction composer from a checklist button
+func (p *Plugin) handleIntroductionDialog(w http.ResponseWriter, req *model.PostActionIntegrationRequest) {
+	tr := p.translationsForUser(req.UserId)
+
+	if err := p.openIntroductionDialog(req.UserId, req.TriggerId); err != nil {
+		p.API.LogError("failed to open introduction dialog", "user_id", req.UserId, "err", err.Error())
+		p.writeIntegrationResponse(w, &model.PostActionIntegrationResponse{EphemeralText: tr.ErrorGeneral})
+		return
+	}
+	p.writeIntegrationResponse(w, &model.PostActionIntegrationResponse{EphemeralText: tr.DialogOpening})
+}
+
+// openIntroductionDialog opens the introduction composer, pre-filled from the
+// user's signature profile and Mattermost profile
+func (p *Plugin) openIntroductionDialog(userID, triggerID string) error {
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		return fmt.Errorf("get user: %w", appErr)
+	}
+
+	defaults := SignatureData{Position: user.Position}
+	if profile, err := p.loadSignatureProfile(userID); err != nil {
+		p.API.LogWarn("failed to load signature profile", "user_id", userID, "err", err.Error())
+	} else if profile != nil {
+		defaults = profile.Data
+	}
+
+	callbackURL, err := p.pluginURL()
+	if err != nil {
+		return err
+	}
+
+	language := p.languageForUser(userID)
+	tr := p.translationsFor(language)
+
+	// Projects are the signature templates that aren't retired
+	templates, err := p.activeSignatureTemplates()
+	if err != nil {
+		return fmt.Errorf("list signature templates: %w", err)
+	}
+	projectOptions := make([]*model.PostActionOptions, 0, len(templates))
+	defaultProject := ""
+	for _, tmpl := range templates {
+		projectOptions = append(projectOptions, &model.PostActionOptions{Text: tmpl.Name.Get(language), Value: tmpl.ID})
+		if tmpl.ID == defaults.Project {
+			defaultProject = tmpl.ID
+		}
+	}
+
+	elements := []model.DialogElement{
+		{
+			DisplayName: tr.DialogIntroRole,
+			Name:        "role",
+			Type:        "text",
+			Placeholder: "Projektkoordinator*in",
+			Default:     defaults.Position,
+			MaxLength:   model.UserPositionMaxRunes,
+			HelpText:    tr.DialogIntroRoleHelp,
+		},
+		{
+			DisplayName: tr.DialogProject,
+			Name:        "project",
+			Type:        "select",
+			Options:     projectOptions,
+			Default:     defaultProject,
+			Optional:    true,
+			HelpText:    tr.DialogIntroProjectHelp,
+		},
+	}
+	elements = append(elements, pronounDialogElements(tr, defaults.Pronouns, defaults.CustomPronouns)...)
+	elements = append(elements, model.DialogElement{
+		DisplayName: tr.DialogIntroFunFact,
+		Name:        "fun_fact",
+		Type:        "textarea",
+		Placeholder: tr.DialogIntroFunFactPlaceholder,
+		Optional:    true,
+		MaxLength:   maxFunFactLength,
+		HelpText:    tr.DialogIntroFunFactHelp,
+	})
+	// Without an uploaded picture there is only the generated default avatar
+	if user.LastPictureUpdate > 0 {
+		elements = append(elements, model.DialogElement{
+			DisplayName: tr.DialogIntroPhoto,
+			Name:        "include_photo",
+			Type:        "bool",
+			Placeholder: tr.DialogIntroPhotoOption,
+			Default:     strconv.FormatBool(true),
+			Optional:    true,
+			HelpText:    tr.DialogIntroPhotoHelp,
+		})
+	}
+
+	dialog := model.OpenDialogRequest{
+		TriggerId: triggerID,
+		URL:       callbackURL + "/submit-introduction",
+		Dialog: model.Dialog{
+			Title:            tr.DialogIntroTitle,
+			IntroductionText: fmt.Sprintf(tr.DialogIntroIntro, p.introductionChannelName()),
+			Elements:         elements,
+			SubmitLabel:      tr.DialogIntroSubmitButton,
+			NotifyOnCancel:   false,
+		},
+	}
+
+	if appErr := p.API.OpenInteractiveDialog(dialog); appErr != nil {
+		return fmt.Errorf("open dialog: %w", appErr)
+	}
+	return nil
+}
+
+// handleIntroductionSubmission posts the introduction and completes the steps
+// that ask for it
+func (p *Plugin) handleIntroductionSubmission(w http.ResponseWriter, r *http.Request, userID string) {
+	var submission model.SubmitDialogRequest
+	if err := json.NewDecoder(r.Body).Decode(&submission); err != nil {
+		p.API.LogError("failed to decode dialog submission", "err", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !p.verifyActingUser(w, r, userID, submission.UserId) {
+		return
+	}
+
+	tr := p.translationsForUser(userID)
+	writeResponse := func(resp *model.SubmitDialogResponse) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			p.API.LogError("failed to encode dialog response", "err", err.Error())
+		}
+	}
+
+	role, _ := submission.Submission["role"].(string)
+	project, _ := submission.Submission["project"].(string)
+	funFact, _ := submission.Submission["fun_fact"].(string)
+	includePhoto, _ := submission.Submission["include_photo"].(bool)
+
+	fieldErrors := make(map[string]string)
+	if strings.TrimSpace(role) == "" {
+		fieldErrors["role"] = tr.DialogFieldRequired
+	}
+	pronouns, customPronouns := readPronounsSubmission(submission.Submission, tr, fieldErrors)
+	if len(fieldErrors) > 0 {
+		writeResponse(&model.SubmitDialogResponse{Errors: fieldErrors})
+		return
+	}
+
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		p.API.LogError("failed to get user", "user_id", userID, "err", appErr.Error())
+		writeResponse(&model.SubmitDialogResponse{Error: tr.ErrorGeneral})
+		return
+	}
+	state, err := p.loadState(userID)
+	if err != nil {
+		p.API.LogError("failed to load onboarding state", "user_id", userID, "err", err.Error())
+		writeResponse(&model.SubmitDialogResponse{Error: tr.ErrorGeneral})
+		return
+	}
+	onboarding := state != nil
+	if !onboarding {
+		// Only used to find the channel
+		state = &OnboardingState{UserID: userID}
+	}
+
+	channel, err := p.findOnboardingChannel(user, state, p.introductionChannelName())
+	if err != nil {
+		p.API.LogError("failed to find introductions channel", "user_id", userID, "err", err.Error())
+		writeResponse(&model.SubmitDialogResponse{Error: tr.ErrorGeneral})
+		return
+	}
+	if channel == nil {
+		writeResponse(&model.SubmitDialogResponse{Error: tr.ErrorIntroductionChannelMissing})
+		return
+	}
+
+	intro := introduction{
+		Role:           strings.TrimSpace(role),
+		Project:        project,
+		Pronouns:       pronouns,
+		CustomPronouns: customPronouns,
+		FunFact:        strings.TrimSpace(funFact),
+		IncludePhoto:   includePhoto && user.LastPictureUpdate > 0,
+	}
+	if err := p.postIntroduction(user, channel, intro); err != nil {
+		p.API.LogError("failed to post introduction", "user_id", userID, "channel_id", channel.Id, "err", err.Error())
+		writeResponse(&model.SubmitDialogResponse{Error: tr.ErrorGeneral})
+		return
+	}
+
+	if onboarding {
+		p.completeIntroductionSteps(user, state, channel)
+	}
+
+	p.API.SendEphemeralPost(userID, &model.Post{
+		UserId:    p.botUserID,
+		ChannelId: submission.ChannelId,
+		Message:   fmt.Sprintf(tr.IntroductionPosted, channel.Name),
+	})
+	writeResponse(&model.SubmitDialogResponse{})
+}
+
+// postIntroduction posts the introduction as the user or, depending on the
+// IntroductionPostAs setting, by the bot on their behalf
+func (p *Plugin) postIntroduction(user *model.User, channel *model.Channel, intro introduction) error {
+	asBot := p.getPluginSetting("IntroductionPostAs", introductionPostAsUser) == introductionPostAsBot
+
+	post := &model.Post{
+		UserId:    user.Id,
+		ChannelId: channel.Id,
+		Message:   p.buildIntroductionMessage(user, intro, asBot),
+	}
+	if asBot {
+		post.UserId = p.botUserID
+	} else if _, appErr := p.API.GetChannelMember(channel.Id, user.Id); appErr != nil {
+		// Users can only post in channels they belong to
+		if _, appErr := p.API.AddChannelMember(channel.Id, user.Id); appErr != nil {
+			return fmt.Errorf("add channel member: %w", appErr)
+		}
+	}
+
+	if intro.IncludePhoto {
+		image, appErr := p.API.GetProfileImage(user.Id)
+		if appErr != nil {
+			return fmt.Errorf("get profile image: %w", appErr)
+		}
+		extension := "png"
+		if http.DetectContentType(image) == "image/jpeg" {
+			extension = "jpg"
+		}
+		fileInfo, appErr := p.API.UploadFile(image, channel.Id, user.Username+"."+extension)
+		if appErr != nil {
+			return fmt.Errorf("upload profile image: %w", appErr)
+		}
+		post.FileIds = []string{fileInfo.Id}
+	}
+
+	if _, appErr := p.API.CreatePost(post); appErr != nil {
+		return fmt.Errorf("create post: %w", appErr)
+	}
+	return nil
+}
+
+// buildIntroductionMessage renders an introduction. It is public, so it uses
+// the configured bot language rather than the user's.
+func (p *Plugin) buildIntroductionMessage(user *model.User, intro introduction, asBot bool) string {
+	language := p.getLanguage()
+	tr := p.translationsFor(language)
+
+	lines := []string{fmt.Sprintf(tr.IntroPostGreeting, displayNameOf(user)), ""}
+	lines = append(lines, fmt.Sprintf(tr.IntroPostRole, intro.Role))
+	if intro.Project != "" {
+		lines = append(lines, fmt.Sprintf(tr.IntroPostProject, p.projectName(intro.Project, language)))
+	}
+	if pronouns := shortPronouns(SignatureData{Pronouns: intro.Pronouns, CustomPronouns: intro.CustomPronouns}); pronouns != "" {
+		lines = append(lines, fmt.Sprintf(tr.IntroPostPronouns, pronouns))
+	}
+	if intro.FunFact != "" {
+		lines = append(lines, fmt.Sprintf(tr.IntroPostFunFact, channelMentionEscaper.Replace(intro.FunFact)))
+	}
+	if asBot {
+		lines = append(lines, "", fmt.Sprintf(tr.IntroPostAttribution, user.Username))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// completeIntroductionSteps checks off the steps offering the composer whose
+// other checks pass; quiz and policy gates still have to be met. The post
+// also counts for checks on the channel, even when the bot posted it.
+func (p *Plugin) completeIntroductionSteps(user *model.User, state *OnboardingState, channel *model.Channel) {
+	if err := p.recordChannelPost(state, channel.Id); err != nil {
+		p.API.LogError("failed to save onboarding state", "user_id", user.Id, "err", err.Error())
+		return
+	}
+
+	for _, step := range p.stepsForState(state) {
+		if state.CompletedSteps[step.ID] || !hasStepAction(step, stepActionIntroduction) {
+			continue
+		}
+		failed, err := p.failedChecks(user, state, p.checksForStep(step, state))
+		if err != nil {
+			if !errors.Is(err, errCheckChannelNotFound) {
+				p.API.LogWarn("failed to verify introduction step", "user_id", user.Id, "step", step.ID, "err", err.Error())
+			}
+			continue
+		}
+		if len(failed) > 0 {
+			continue
+		}
+		if err := p.setStepCompleted(state, step.ID, true, user.Id, eventSourceIntroduction); err != nil {
+			p.API.LogError("failed to complete introduction step", "user_id", user.Id, "step", step.ID, "err", err.Error())
+		}
+	}
+
+	p.verifyOpenSteps(user, state)
+	p.refreshChecklistPost(user, state)
+}
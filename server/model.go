@@ -1,31 +1,45 @@
 package main
 
-import "time"
+import (
+	"errors"
+	"time"
+)
 
 type OnboardingState struct {
 	UserID         string          `json:"user_id"`
 	CompletedSteps map[string]bool `json:"completed_steps"`
-	StartedAt      time.Time       `json:"started_at"`
-	LastUpdated    time.Time       `json:"last_updated"`
+	Track          string          `json:"track,omitempty"`
+	// TrackFinal is set once the track was selected with the user's teams
+	// known, or assigned by an admin; later team joins keep it
+	TrackFinal bool `json:"track_final,omitempty"`
+	// TeamID is the team whose join started onboarding, if any
+	TeamID string `json:"team_id,omitempty"`
+	// Language overrides the user's Mattermost locale for onboarding messages
+	Language string `json:"language,omitempty"`
+	// ChecklistPostID is the most recent checklist post in the bot DM
+	ChecklistPostID string `json:"checklist_post_id,omitempty"`
+	// PostedChannels holds the ids of checked channels the user posted in
+	PostedChannels map[string]bool `json:"posted_channels,omitempty"`
+	// AutoJoinedChannels are the names of channels the plugin added the user to
+	AutoJoinedChannels []string  `json:"auto_joined_channels,omitempty"`
+	ManagerID          string    `json:"manager_id,omitempty"`
+	BuddyID            string    `json:"buddy_id,omitempty"`
+	StartedAt          time.Time `json:"started_at"`
+	LastUpdated        time.Time `json:"last_updated"`
+	// LastProgressAt is when a step was last completed or reopened. Unlike
+	// LastUpdated it isn't touched by saves that aren't progress.
+	LastProgressAt time.Time `json:"last_progress_at,omitzero"`
+	// CompletedAt is set the first time every step of the checklist is done
+	CompletedAt time.Time `json:"completed_at,omitzero"`
 }
 
 const (
 	onboardingKVPrefix = "onboarding:user:"
-)
 
-var onboardingSteps = []string{
-	"accounts",
-	"profile",
-	"channels",
-	"tools",
-	"policies",
-	"intro",
-}
+	// updateStateAttempts bounds retries when concurrent hooks race on a state
+	updateStateAttempts = 5
+)
 
-var onboardingStepSet = func() map[string]struct{} {
-	m := make(map[string]struct{}, len(onboardingSteps))
-	for _, step := range onboardingSteps {
-		m[step] = struct{}{}
-	}
-	return m
-}()
+// errStateNotFound means the state was deleted, e.g. by a reset, before it
+// could be updated
+var errStateNotFound = errors.New("onboarding state not found")
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -9,7 +10,9 @@ import (
 	"github.com/mattermost/mattermost/server/public/model"
 )
 
-func (p *Plugin) startOnboardingForUser(user *model.User) error {
+// startOnboardingForUser creates the user's state and sends the checklist.
+// teamID is the team whose join triggered onboarding, if any.
+func (p *Plugin) startOnboardingForUser(user *model.User, teamID string) error {
 	// Idempotent: if we already have state, don’t re-start
 	existing, err := p.loadState(user.Id)
 	if err != nil {
@@ -19,52 +22,111 @@ func (p *Plugin) startOnboardingForUser(user *model.User) error {
 		return nil
 	}
 
+	track, hasTeams := p.selectTrack(user)
 	state := &OnboardingState{
 		UserID:         user.Id,
 		CompletedSteps: map[string]bool{},
+		Track:          track,
+		TrackFinal:     hasTeams,
+		TeamID:         teamID,
 		StartedAt:      time.Now().UTC(),
 	}
-	if err := p.saveState(state); err != nil {
+	// Account creation and team join hooks can race; only one may create the state
+	created, err := p.createState(state)
+	if err != nil || !created {
 		return err
 	}
 
+	if _, err := p.postChecklist(user, state); err != nil {
+		return err
+	}
+
+	// Joining fires UserHasJoinedChannel, which may complete steps; with the
+	// checklist saved first, the hook can update the post too
+	if joined := p.autoJoinChannels(user, state); len(joined) > 0 {
+		if err := p.recordAutoJoinedChannels(state, joined); err != nil {
+			p.API.LogWarn("failed to save auto-joined channels", "user_id", user.Id, "err", err.Error())
+		} else {
+			p.refreshChecklistPost(user, state)
+		}
+	}
+
+	// Profiles and channel memberships may already pass some checks
+	p.verifyUserSteps(state)
+	return nil
+}
+
+// postChecklist sends the welcome message with the checklist into the bot DM
+// and remembers the post so it can be updated later.
+func (p *Plugin) postChecklist(user *model.User, state *OnboardingState) (*model.Post, error) {
 	// Open DM channel between bot and user
 	channel, appErr := p.API.GetDirectChannel(p.botUserID, user.Id)
 	if appErr != nil {
-		return appErr
+		return nil, appErr
 	}
 
-	displayName := user.GetFullName()
-	if displayName == "" {
-		displayName = user.Username
+	post := &model.Post{
+		UserId:    p.botUserID,
+		ChannelId: channel.Id,
+		Message:   p.buildWelcomeMessage(user, state),
 	}
 
-	teamName := p.lookupPrimaryTeamName(user)
+	created, appErr := p.API.CreatePost(post)
+	if appErr != nil {
+		return nil, appErr
+	}
 
-	// Get translations
-	tr := p.getTranslations()
+	// Button contexts are signed for the post id, which only exists now
+	created.AddProp("attachments", p.buildChecklistAttachments(state, created.Id))
+	if created, appErr = p.API.UpdatePost(created); appErr != nil {
+		return nil, appErr
+	}
 
-	welcomeMsg := fmt.Sprintf(tr.WelcomeGreeting, displayName, teamName) + "\n\n" +
-		tr.WelcomeIntro + "\n\n" +
-		tr.WelcomeClosing
+	if err := p.updateState(state, func(s *OnboardingState) { s.ChecklistPostID = created.Id }); err != nil {
+		return nil, err
+	}
 
-	post := &model.Post{
-		UserId:    p.botUserID,
-		ChannelId: channel.Id,
-		Message:   welcomeMsg,
-		Props: map[string]interface{}{
-			"attachments": p.buildChecklistAttachments(state),
-		},
+	return created, nil
+}
+
+// refreshChecklistPost updates the stored checklist post after a change that
+// didn't come from a button click (e.g. an admin command).
+func (p *Plugin) refreshChecklistPost(user *model.User, state *OnboardingState) {
+	if state.ChecklistPostID == "" {
+		return
 	}
 
-	if _, appErr := p.API.CreatePost(post); appErr != nil {
-		return appErr
+	post, appErr := p.API.GetPost(state.ChecklistPostID)
+	if appErr != nil {
+		p.API.LogWarn("failed to get checklist post", "user_id", user.Id, "post_id", state.ChecklistPostID, "err", appErr.Error())
+		return
 	}
 
-	return nil
+	post.Message = p.buildWelcomeMessage(user, state)
+	post.AddProp("attachments", p.buildChecklistAttachments(state, post.Id))
+	if _, appErr := p.API.UpdatePost(post); appErr != nil {
+		p.API.LogWarn("failed to update checklist post", "user_id", user.Id, "post_id", post.Id, "err", appErr.Error())
+	}
+}
+
+// buildWelcomeMessage renders the greeting shown above the checklist
+func (p *Plugin) buildWelcomeMessage(user *model.User, state *OnboardingState) string {
+	teamName := p.lookupTeamName(user, state)
+
+	// Get translations
+	tr := p.translationsFor(p.resolveLanguage(user, state))
+
+	message := fmt.Sprintf(tr.WelcomeGreeting, displayNameOf(user), teamName) + "\n\n" +
+		tr.WelcomeIntro + "\n\n"
+	if line := autoJoinedChannelsLine(state, tr); line != "" {
+		message += line + "\n\n"
+	}
+	return message + tr.WelcomeClosing
 }
 
-func (p *Plugin) buildChecklistAttachments(state *OnboardingState) []*model.SlackAttachment {
+// buildChecklistAttachments renders one attachment per step. Button contexts
+// are signed for the user and the checklist post they belong to.
+func (p *Plugin) buildChecklistAttachments(state *OnboardingState, postID string) []*model.SlackAttachment {
 	pluginURL, err := p.pluginURL()
 	if err != nil {
 		p.API.LogError("pluginURL not configured", "err", err.Error())
@@ -72,121 +134,85 @@ func (p *Plugin) buildChecklistAttachments(state *OnboardingState) []*model.Slac
 	}
 	callbackURL := pluginURL + "/complete-step"
 
-	isDone := func(step string) bool {
-		return state.CompletedSteps[step]
-	}
-
 	// Get translations
-	tr := p.getTranslations()
-
-	return []*model.SlackAttachment{
-		{
-			Title: tr.Step1Title,
-			Text:  checkbox(isDone("accounts")) + " " + tr.Step1Description + tr.Step1Link,
-			Actions: []*model.PostAction{
-				{
-					Name: tr.ButtonMarkAccountsReady,
-					Type: model.PostActionTypeButton,
-					Integration: &model.PostActionIntegration{
-						URL: callbackURL,
-						Context: map[string]interface{}{
-							"step": "accounts",
-						},
-					},
-				},
-			},
-		},
-		{
-			Title: tr.Step2Title,
-			Text:  checkbox(isDone("profile")) + " " + tr.Step2Description + tr.Step2Link,
-			Actions: []*model.PostAction{
-				{
+	language := p.languageForState(state)
+	tr := p.translationsFor(language)
+
+	steps := p.stepsForState(state)
+	quizzes := p.getQuizzes()
+	attachments := make([]*model.SlackAttachment, 0, len(steps))
+	for i, step := range steps {
+		var actions []*model.PostAction
+		for _, action := range step.Actions {
+			switch action {
+			case stepActionSignature:
+				actions = append(actions, &model.PostAction{
 					Name: tr.ButtonGenerateSignature,
 					Type: model.PostActionTypeButton,
 					Integration: &model.PostActionIntegration{
-						URL: callbackURL,
-						Context: map[string]interface{}{
-							"action": "open_signature_dialog",
-						},
+						URL:     callbackURL,
+						Context: p.signActionContext(state.UserID, postID, actionOpenSignatureDialog, step.ID),
 					},
-				},
-				{
-					Name: tr.ButtonMarkProfileComplete,
+				})
+			case stepActionPolicies:
+				actions = append(actions, p.buildPolicyActions(state, postID, pluginURL+"/policy-action", tr, language)...)
+			case stepActionIntroduction:
+				actions = append(actions, &model.PostAction{
+					Name: tr.ButtonComposeIntroduction,
 					Type: model.PostActionTypeButton,
 					Integration: &model.PostActionIntegration{
-						URL: callbackURL,
-						Context: map[string]interface{}{
-							"step": "profile",
-						},
+						URL:     callbackURL,
+						Context: p.signActionContext(state.UserID, postID, actionOpenIntroDialog, step.ID),
 					},
-				},
-			},
-		},
-		{
-			Title: tr.Step3Title,
-			Text:  checkbox(isDone("channels")) + " " + tr.Step3Description + tr.Step3Link,
-			Actions: []*model.PostAction{
-				{
-					Name: tr.ButtonMarkChannelsJoined,
-					Type: model.PostActionTypeButton,
-					Integration: &model.PostActionIntegration{
-						URL: callbackURL,
-						Context: map[string]interface{}{
-							"step": "channels",
-						},
-					},
-				},
-			},
-		},
-		{
-			Title: tr.Step4Title,
-			Text:  checkbox(isDone("tools")) + " " + tr.Step4Description + tr.Step4Link,
-			Actions: []*model.PostAction{
-				{
-					Name: tr.ButtonMarkToolsReady,
-					Type: model.PostActionTypeButton,
-					Integration: &model.PostActionIntegration{
-						URL: callbackURL,
-						Context: map[string]interface{}{
-							"step": "tools",
-						},
-					},
-				},
-			},
-		},
-		{
-			Title: tr.Step5Title,
-			Text:  checkbox(isDone("policies")) + " " + tr.Step5Description + tr.Step5Link,
-			Actions: []*model.PostAction{
-				{
-					Name: tr.ButtonMarkPoliciesReviewed,
-					Type: model.PostActionTypeButton,
-					Integration: &model.PostActionIntegration{
-						URL: callbackURL,
-						Context: map[string]interface{}{
-							"step": "policies",
-						},
-					},
-				},
-			},
-		},
-		{
-			Title: tr.Step6Title,
-			Text:  checkbox(isDone("intro")) + " " + tr.Step6Description + tr.Step6Link,
-			Actions: []*model.PostAction{
-				{
-					Name: tr.ButtonMarkIntrosDone,
+				})
+			}
+		}
+
+		quizLine := ""
+		if quiz, ok := findQuiz(quizzes, step.ID); ok {
+			var canStart bool
+			quizLine, canStart = p.renderQuizLine(state, quiz, tr)
+			if canStart && !state.CompletedSteps[step.ID] {
+				actions = append(actions, &model.PostAction{
+					Name: tr.ButtonStartQuiz,
 					Type: model.PostActionTypeButton,
 					Integration: &model.PostActionIntegration{
-						URL: callbackURL,
-						Context: map[string]interface{}{
-							"step": "intro",
-						},
+						URL:     callbackURL,
+						Context: p.signActionContext(state.UserID, postID, actionStartQuiz, step.ID),
 					},
-				},
+				})
+			}
+		}
+
+		buttonLabel := step.ButtonLabel.Get(language)
+		if buttonLabel == "" {
+			buttonLabel = step.Title.Get(language)
+		}
+		if state.CompletedSteps[step.ID] {
+			buttonLabel = tr.ButtonUncheckStep
+		}
+		actions = append(actions, &model.PostAction{
+			Name: buttonLabel,
+			Type: model.PostActionTypeButton,
+			Integration: &model.PostActionIntegration{
+				URL:     callbackURL,
+				Context: p.signActionContext(state.UserID, postID, actionToggleStep, step.ID),
 			},
-		},
+		})
+
+		text := checkbox(state.CompletedSteps[step.ID]) + " " + renderStepText(step, language)
+		if hasStepAction(step, stepActionPolicies) {
+			text += p.renderPolicyLines(state, tr, language)
+		}
+		text += quizLine
+		attachments = append(attachments, &model.SlackAttachment{
+			Title:   fmt.Sprintf(tr.StepTitleFormat, i+1, step.Title.Get(language)),
+			Text:    text,
+			Actions: actions,
+		})
 	}
+
+	return attachments
 }
 
 func checkbox(done bool) string {
@@ -197,110 +223,156 @@ func checkbox(done bool) string {
 }
 
 // Handle integration callback when user clicks a button
-func (p *Plugin) handleCompleteStep(w http.ResponseWriter, r *http.Request) {
+func (p *Plugin) handleCompleteStep(w http.ResponseWriter, r *http.Request, userID string) {
 	var req model.PostActionIntegrationRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		p.API.LogError("failed to decode integration request", "err", err.Error())
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	if !p.verifyActingUser(w, r, userID, req.UserId) {
+		return
+	}
 
-	userID := req.UserId
+	state, err := p.loadState(userID)
+	if err != nil {
+		p.API.LogError("failed to load onboarding state", "user_id", userID, "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 
-	// Check if this is a signature generator action
-	if actionRaw, ok := req.Context["action"]; ok {
-		action, _ := actionRaw.(string)
-		if action == "open_signature_dialog" {
-			p.handleSignatureDialog(w, r, &req)
+	// Buttons only work on the user's current checklist post
+	if err := p.verifyChecklistPost(userID, req.PostId, state); err != nil {
+		if errors.Is(err, errOutdatedChecklistPost) {
+			p.writeIntegrationResponse(w, &model.PostActionIntegrationResponse{
+				EphemeralText: p.translationsForUser(userID).ErrorChecklistOutdated,
+			})
 			return
 		}
+		p.API.LogWarn("rejected button click on foreign post", "user_id", userID, "post_id", req.PostId)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
 	}
 
-	stepRaw, ok := req.Context["step"]
-	if !ok {
-		w.WriteHeader(http.StatusBadRequest)
+	// Only contexts signed by buildChecklistAttachments are accepted
+	action, step, err := p.verifyActionContext(userID, req.PostId, req.Context)
+	if errors.Is(err, errActionSignatureExpired) || errors.Is(err, errActionSignatureMissing) {
+		// The post is the user's current checklist; re-rendering it is safe
+		p.respondWithChecklist(w, &req, state, p.translationsForUser(userID).ErrorActionExpired)
+		return
+	}
+	if err != nil {
+		p.API.LogWarn("rejected button click with invalid signature", "user_id", userID, "post_id", req.PostId)
+		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
 
-	step, _ := stepRaw.(string)
+	switch action {
+	case actionOpenSignatureDialog:
+		p.handleSignatureDialog(w, r, &req)
+		return
+	case actionOpenIntroDialog:
+		p.handleIntroductionDialog(w, &req)
+		return
+	case actionStartQuiz:
+		p.handleStartQuiz(w, &req, state, step)
+		return
+	case actionToggleStep:
+		// Handled below
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
 
-	if !isAllowedStep(step) {
+	// Validate against the steps of the user's track
+	stepDef, ok := findStep(p.stepsForState(state), step)
+	if !ok {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	state, err := p.loadState(userID)
-	if err != nil {
-		p.API.LogError("failed to load onboarding state", "user_id", userID, "err", err.Error())
+	// Clicking the button again unchecks the step
+	completed := !state.CompletedSteps[step]
+
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		p.API.LogError("failed to get user", "user_id", userID, "err", appErr.Error())
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	if state == nil {
-		state = &OnboardingState{
-			UserID:         userID,
-			CompletedSteps: map[string]bool{},
+
+	// Get translations
+	language := p.resolveLanguage(user, state)
+	tr := p.translationsFor(language)
+
+	// Checks run on every click, so other steps may be completed along the way.
+	// Steps with failing checks can't be completed by hand.
+	_, missing := p.verifyOpenSteps(user, state)
+	if completed && len(missing[step]) > 0 {
+		p.respondWithChecklist(w, &req, state, p.describeMissingChecks(stepDef, missing[step], language))
+		return
+	}
+
+	if state.CompletedSteps[step] != completed {
+		if err := p.setStepCompleted(state, step, completed, userID, eventSourceButton); err != nil {
+			p.API.LogError("failed to save onboarding state", "user_id", userID, "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
 		}
 	}
 
-	state.CompletedSteps[step] = true
-	if err := p.saveState(state); err != nil {
-		p.API.LogError("failed to save onboarding state", "user_id", userID, "err", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+	stepMessage := tr.StepMarkedComplete
+	if !completed {
+		stepMessage = tr.StepMarkedIncomplete
 	}
 
-	// Rebuild attachments to reflect updated checkboxes
-	attachments := p.buildChecklistAttachments(state)
+	p.respondWithChecklist(w, &req, state, fmt.Sprintf(stepMessage, stepDef.Title.Get(language)))
+}
 
+// respondWithChecklist answers a button click by re-rendering the checklist
+// post, which also re-signs its buttons
+func (p *Plugin) respondWithChecklist(w http.ResponseWriter, req *model.PostActionIntegrationRequest, state *OnboardingState, ephemeralText string) {
 	// Get user info to rebuild welcome message
-	user, appErr := p.API.GetUser(userID)
+	user, appErr := p.API.GetUser(state.UserID)
 	if appErr != nil {
 		p.API.LogError("failed to get user", "err", appErr.Error())
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	displayName := user.GetFullName()
-	if displayName == "" {
-		displayName = user.Username
-	}
-
-	teamName := p.lookupPrimaryTeamName(user)
-
-	// Get translations
-	tr := p.getTranslations()
-
-	// Rebuild welcome message
-	welcomeMsg := fmt.Sprintf(tr.WelcomeGreeting, displayName, teamName) + "\n\n" +
-		tr.WelcomeIntro + "\n\n" +
-		tr.WelcomeClosing
-
 	// Respond with updated message that includes welcome text
 	resp := &model.PostActionIntegrationResponse{
 		Update: &model.Post{
 			Id:        req.PostId,
 			ChannelId: req.ChannelId,
 			UserId:    req.UserId,
-			Message:   welcomeMsg,
+			Message:   p.buildWelcomeMessage(user, state),
 			Props: map[string]interface{}{
-				"attachments": attachments,
+				"attachments": p.buildChecklistAttachments(state, req.PostId),
 			},
 		},
-		EphemeralText: fmt.Sprintf(tr.StepMarkedComplete, step),
+		EphemeralText: ephemeralText,
 	}
 
+	p.writeIntegrationResponse(w, resp)
+}
+
+func (p *Plugin) writeIntegrationResponse(w http.ResponseWriter, resp *model.PostActionIntegrationResponse) {
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		p.API.LogError("failed to encode integration response", "err", err.Error())
 	}
 }
 
-func isAllowedStep(step string) bool {
-	_, ok := onboardingStepSet[step]
-	return ok
-}
+// lookupTeamName returns the display name of the team onboarding started in,
+// or of the user's primary team.
+func (p *Plugin) lookupTeamName(user *model.User, state *OnboardingState) string {
+	if state != nil && state.TeamID != "" {
+		if team, appErr := p.API.GetTeam(state.TeamID); appErr == nil {
+			return team.DisplayName
+		}
+	}
 
-func (p *Plugin) lookupPrimaryTeamName(user *model.User) string {
 	memberships, appErr := p.API.GetTeamsForUser(user.Id)
 	if appErr != nil || len(memberships) == 0 {
 		return "Mattermost"
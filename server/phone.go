@@ -0,0 +1,258 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// House style labels of the phone lines in signatures. Signatures are
+// bilingual, so these don't depend on the user's language.
+const (
+	workNumberLabel   = "Tel.:"
+	mobileNumberLabel = "Mobil:"
+)
+
+// germanCountryCode is assumed for numbers without a country code
+const germanCountryCode = "49"
+
+var (
+	errPhoneNumberInvalid = errors.New("invalid phone number")
+	errPhoneNumberLength  = errors.New("phone number has too few or too many digits")
+)
+
+// phoneLabelPattern matches labels users type in front of numbers
+var phoneLabelPattern = regexp.MustCompile(`(?i)^\s*(tel(efon)?|phone|fon|mobil(e)?|handy|mob|cell)\.?\s*:?\s*`)
+
+// phoneAllowedPattern matches everything a typed phone number may contain
+var phoneAllowedPattern = regexp.MustCompile(`^\+?[0-9 ()/.\-]+$`)
+
+// phoneExtensionPattern matches an extension marked as such at the end of a
+// number, e.g. "ext. 12", "x12" or "Durchwahl 12"
+var phoneExtensionPattern = regexp.MustCompile(`(?i)\s*(ext|x|durchwahl|dw|app)\.?\s*:?\s*([0-9]{1,6})\s*$`)
+
+// phoneHyphenExtensionPattern matches a German extension after a hyphen at
+// the end of a number, e.g. "030 12345-12". The part before it must contain
+// more than the area code, so "0171-1234567" stays one number. Elsewhere the
+// hyphen is just a separator, as in "+1 212 555-0123".
+var phoneHyphenExtensionPattern = regexp.MustCompile(`^(.*[0-9][ ()/.]+[0-9]+)\s*-\s*([0-9]{1,4})$`)
+
+// twoDigitCountryCodes are the ITU country codes with two digits. 1 and 7 have
+// one digit; all others have three.
+var twoDigitCountryCodes = map[string]bool{
+	"20": true, "27": true, "30": true, "31": true, "32": true, "33": true, "34": true, "36": true, "39": true,
+	"40": true, "41": true, "43": true, "44": true, "45": true, "46": true, "47": true, "48": true, "49": true,
+	"51": true, "52": true, "53": true, "54": true, "55": true, "56": true, "57": true, "58": true,
+	"60": true, "61": true, "62": true, "63": true, "64": true, "65": true, "66": true,
+	"81": true, "82": true, "84": true, "86": true,
+	"90": true, "91": true, "92": true, "93": true, "94": true, "95": true, "98": true,
+}
+
+// germanTwoDigitAreaCodes are the German area codes with two digits
+var germanTwoDigitAreaCodes = map[string]bool{"30": true, "40": true, "69": true, "89": true}
+
+// PhoneNumber is a parsed phone number
+type PhoneNumber struct {
+	// CountryCode without "+", e.g. "49"
+	CountryCode string
+	// AreaCode is the German area or mobile prefix without the trunk "0", if known
+	AreaCode string
+	// Subscriber holds the remaining digit groups
+	Subscriber []string
+	// Extension is the direct-dial suffix, if any
+	Extension string
+}
+
+// parsePhoneNumber parses German numbers ("030 12345678", "030/123 45 678")
+// and international ones ("+49 30 12345678", "0049 (0)30 12345678",
+// "+44 20 7946 0958"). Labels like "Tel.:" are ignored, and extensions
+// ("030 12345-12", "+44 20 7946 0958 ext. 12") are kept apart.
+func parsePhoneNumber(raw string) (PhoneNumber, error) {
+	number := strings.TrimSpace(phoneLabelPattern.ReplaceAllString(raw, ""))
+	number, extension := splitPhoneExtension(number)
+	if number == "" || !phoneAllowedPattern.MatchString(number) {
+		return PhoneNumber{}, errPhoneNumberInvalid
+	}
+
+	// "+49 (0)30" keeps the German trunk prefix for local callers; drop it
+	number = strings.Replace(number, "(0)", " ", 1)
+
+	// Split into the digit groups the user typed
+	groups := strings.FieldsFunc(number, func(r rune) bool {
+		return r < '0' || r > '9'
+	})
+	if len(groups) == 0 {
+		return PhoneNumber{}, errPhoneNumberInvalid
+	}
+
+	var parsed PhoneNumber
+	switch {
+	case strings.HasPrefix(number, "+"):
+		parsed.CountryCode, groups = splitCountryCode(groups)
+	case strings.HasPrefix(groups[0], "00"):
+		groups[0] = groups[0][2:]
+		parsed.CountryCode, groups = splitCountryCode(groups)
+	case strings.HasPrefix(groups[0], "0"):
+		parsed.CountryCode = germanCountryCode
+		groups[0] = groups[0][1:]
+	default:
+		// Without trunk prefix or country code we can't tell what was meant
+		return PhoneNumber{}, errPhoneNumberInvalid
+	}
+	groups = dropEmpty(groups)
+
+	digits := strings.Join(groups, "")
+	if len(parsed.CountryCode)+len(digits) < 7 || len(parsed.CountryCode)+len(digits) > 15 {
+		return PhoneNumber{}, errPhoneNumberLength
+	}
+
+	if parsed.CountryCode == germanCountryCode {
+		if strings.HasPrefix(digits, "0") {
+			return PhoneNumber{}, errPhoneNumberInvalid
+		}
+		if len(digits) < 6 {
+			return PhoneNumber{}, errPhoneNumberLength
+		}
+		parsed.AreaCode, parsed.Subscriber = splitGermanAreaCode(groups)
+		parsed.Extension = extension
+		return parsed, nil
+	}
+
+	parsed.Subscriber = groups
+	parsed.Extension = extension
+	return parsed, nil
+}
+
+// splitPhoneExtension takes a trailing extension off a number
+func splitPhoneExtension(number string) (string, string) {
+	if match := phoneExtensionPattern.FindStringSubmatchIndex(number); match != nil {
+		return strings.TrimSpace(number[:match[0]]), number[match[4]:match[5]]
+	}
+	german := strings.HasPrefix(number, "+"+germanCountryCode) || strings.HasPrefix(number, "00"+germanCountryCode) ||
+		(strings.HasPrefix(number, "0") && !strings.HasPrefix(number, "00"))
+	if !german {
+		return number, ""
+	}
+	if match := phoneHyphenExtensionPattern.FindStringSubmatch(number); match != nil {
+		return strings.TrimSpace(match[1]), match[2]
+	}
+	return number, ""
+}
+
+// splitCountryCode takes the country code off the first digit groups
+func splitCountryCode(groups []string) (string, []string) {
+	digits := strings.Join(groups, "")
+	length := 3
+	switch {
+	case strings.HasPrefix(digits, "1") || strings.HasPrefix(digits, "7"):
+		length = 1
+	case len(digits) >= 2 && twoDigitCountryCodes[digits[:2]]:
+		length = 2
+	}
+	if len(digits) <= length {
+		return digits, nil
+	}
+
+	// Remove length digits from the front, group by group
+	code := digits[:length]
+	for length > 0 && len(groups) > 0 {
+		if len(groups[0]) <= length {
+			length -= len(groups[0])
+			groups = groups[1:]
+			continue
+		}
+		groups[0] = groups[0][length:]
+		length = 0
+	}
+	return code, groups
+}
+
+// splitGermanAreaCode separates the area or mobile prefix of a German number.
+// A group the user typed on its own is taken as the area code; otherwise
+// well-known prefixes are recognized.
+func splitGermanAreaCode(groups []string) (string, []string) {
+	if len(groups) > 1 {
+		return groups[0], groups[1:]
+	}
+
+	digits := groups[0]
+	length := 0
+	switch {
+	case len(digits) >= 2 && germanTwoDigitAreaCodes[digits[:2]]:
+		length = 2
+	case strings.HasPrefix(digits, "15"), strings.HasPrefix(digits, "16"), strings.HasPrefix(digits, "17"):
+		length = 3
+	}
+	if length == 0 || len(digits) <= length {
+		return "", groups
+	}
+	return digits[:length], []string{digits[length:]}
+}
+
+// String renders the number in house style: "+49 30 1234 5678". German
+// extensions follow a hyphen as in DIN 5008 ("+49 30 1234 5678-12"), others
+// are written as "ext. 12".
+func (n PhoneNumber) String() string {
+	parts := []string{"+" + n.CountryCode}
+	if n.AreaCode != "" {
+		parts = append(parts, n.AreaCode)
+	}
+	switch {
+	case n.CountryCode == germanCountryCode && n.AreaCode != "":
+		// German subscriber numbers are grouped in fours from the right
+		parts = append(parts, groupDigits(strings.Join(n.Subscriber, ""))...)
+	case n.CountryCode == germanCountryCode:
+		// Without a known area code any grouping could split it
+		parts = append(parts, strings.Join(n.Subscriber, ""))
+	default:
+		parts = append(parts, n.Subscriber...)
+	}
+
+	number := strings.Join(parts, " ")
+	switch {
+	case n.Extension == "":
+		return number
+	case n.CountryCode == germanCountryCode:
+		return number + "-" + n.Extension
+	default:
+		return number + " ext. " + n.Extension
+	}
+}
+
+// groupDigits splits digits into groups of four from the right. Short
+// numbers stay in one piece.
+func groupDigits(digits string) []string {
+	if len(digits) <= 5 {
+		return []string{digits}
+	}
+	var groups []string
+	for len(digits) > 4 {
+		groups = append([]string{digits[len(digits)-4:]}, groups...)
+		digits = digits[:len(digits)-4]
+	}
+	return append([]string{digits}, groups...)
+}
+
+// normalizePhoneNumber returns a number in house style, or the input without
+// its label if it can't be parsed. Signatures built from older submissions
+// still render that way.
+func normalizePhoneNumber(raw string) string {
+	if strings.TrimSpace(raw) == "" {
+		return ""
+	}
+	parsed, err := parsePhoneNumber(raw)
+	if err != nil {
+		return strings.TrimSpace(phoneLabelPattern.ReplaceAllString(raw, ""))
+	}
+	return parsed.String()
+}
+
+func dropEmpty(groups []string) []string {
+	kept := groups[:0]
+	for _, group := range groups {
+		if group != "" {
+			kept = append(kept, group)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePhoneNumber(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+		err  error
+	}{
+		{raw: "030 12345678", want: "+49 30 1234 5678"},
+		{raw: "Tel.: 030/123 45 678", want: "+49 30 1234 5678"},
+		{raw: "+49 (0)30 12345678", want: "+49 30 1234 5678"},
+		{raw: "0049 30 12345678", want: "+49 30 1234 5678"},
+		{raw: "0171-1234567", want: "+49 171 123 4567"},
+		{raw: "Mobil: 01711234567", want: "+49 171 123 4567"},
+		{raw: "+44 20 7946 0958", want: "+44 20 7946 0958"},
+		{raw: "+1 212 555-0123", want: "+1 212 555 0123"},
+
+		// Extensions are kept apart from the number
+		{raw: "030 12345-12", want: "+49 30 12345-12"},
+		{raw: "030 123456 - 7", want: "+49 30 12 3456-7"},
+		{raw: "+49 30 12345678 ext. 12", want: "+49 30 1234 5678-12"},
+		{raw: "030 12345678 Durchwahl 3", want: "+49 30 1234 5678-3"},
+		{raw: "+44 20 7946 0958 ext. 12", want: "+44 20 7946 0958 ext. 12"},
+		{raw: "+44 20 7946 0958 x12", want: "+44 20 7946 0958 ext. 12"},
+
+		{raw: "12345678", err: errPhoneNumberInvalid},
+		{raw: "call me", err: errPhoneNumberInvalid},
+		{raw: "+49 030 12345678", err: errPhoneNumberInvalid},
+		{raw: "030 12", err: errPhoneNumberLength},
+		{raw: "+49 30 1234567890123456", err: errPhoneNumberLength},
+	}
+	for _, test := range tests {
+		parsed, err := parsePhoneNumber(test.raw)
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("parsePhoneNumber(%q): got error %v, want %v", test.raw, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parsePhoneNumber(%q): unexpected error %v", test.raw, err)
+			continue
+		}
+		if got := parsed.String(); got != test.want {
+			t.Errorf("parsePhoneNumber(%q) = %q, want %q", test.raw, got, test.want)
+		}
+	}
+}
+
+func TestSplitPhoneExtension(t *testing.T) {
+	tests := []struct {
+		number    string
+		base      string
+		extension string
+	}{
+		{number: "030 12345-12", base: "030 12345", extension: "12"},
+		{number: "+49 30 12345 - 12", base: "+49 30 12345", extension: "12"},
+		{number: "030 12345 ext. 12", base: "030 12345", extension: "12"},
+		{number: "030 12345 DW: 12", base: "030 12345", extension: "12"},
+		{number: "0171-1234567", base: "0171-1234567"},
+		{number: "030-12345678", base: "030-12345678"},
+		{number: "+1 212 555-0123", base: "+1 212 555-0123"},
+	}
+	for _, test := range tests {
+		base, extension := splitPhoneExtension(test.number)
+		if base != test.base || extension != test.extension {
+			t.Errorf("splitPhoneExtension(%q) = %q, %q, want %q, %q", test.number, base, extension, test.base, test.extension)
+		}
+	}
+}
+
+func TestNormalizePhoneNumber(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{raw: "", want: ""},
+		{raw: "Tel.: 030 12345-12", want: "+49 30 12345-12"},
+		// Numbers that can't be parsed keep their text without the label
+		{raw: "Tel.: 12345", want: "12345"},
+	}
+	for _, test := range tests {
+		if got := normalizePhoneNumber(test.raw); got != test.want {
+			t.Errorf("normalizePhoneNumber(%q) = %q, want %q", test.raw, got, test.want)
+		}
+	}
+}
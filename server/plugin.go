@@ -7,16 +7,44 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
 )
 
 type Plugin struct {
 	plugin.MattermostPlugin
 
 	botUserID string
+
+	reminderJob *cluster.Job
+
+	// actionSecret signs checklist button contexts
+	actionSecret []byte
+
+	translations translationStore
+
+	settings settingsCache
+
+	// missingCheckChannels remembers the unknown channels of step checks
+	// already logged, so the misconfiguration is reported once
+	missingCheckChannels sync.Map
+}
+
+// settingsCache holds settings parsed in OnConfigurationChange that are read
+// on hot paths, such as every post
+type settingsCache struct {
+	sync.RWMutex
+	steps    []StepDefinition
+	tracks   []TrackDefinition
+	quizzes  []QuizDefinition
+	policies []PolicyDefinition
+	// channelPostChecks is set when any step checks for a channel post
+	channelPostChecks bool
 }
 
 const botUserKVKey = "onboarding:bot_user_id"
@@ -27,14 +55,48 @@ const botIconPath = "assets/icon.png"
 
 // OnActivate runs when the plugin is enabled.
 func (p *Plugin) OnActivate() error {
+	if err := p.reloadTranslations(); err != nil {
+		return fmt.Errorf("load translations: %w", err)
+	}
+
 	if err := p.ensureBotUser(); err != nil {
 		return err
 	}
 
+	if err := p.ensureActionSecret(); err != nil {
+		return fmt.Errorf("load action secret: %w", err)
+	}
+
+	if err := p.ensureDefaultSignatureTemplates(); err != nil {
+		return fmt.Errorf("seed signature templates: %w", err)
+	}
+
+	if err := p.syncPolicyVersions(); err != nil {
+		p.API.LogWarn("failed to check policy versions", "err", err.Error())
+	}
+
+	if err := p.registerCommands(); err != nil {
+		return fmt.Errorf("register commands: %w", err)
+	}
+
+	if err := p.startReminderJob(); err != nil {
+		return err
+	}
+
 	p.API.LogInfo("Onboarding plugin activated", "bot_user_id", p.botUserID)
 	return nil
 }
 
+// OnDeactivate stops background jobs.
+func (p *Plugin) OnDeactivate() error {
+	if p.reminderJob != nil {
+		if err := p.reminderJob.Close(); err != nil {
+			p.API.LogWarn("failed to stop reminder job", "err", err.Error())
+		}
+	}
+	return nil
+}
+
 func (p *Plugin) ensureBotUser() error {
 	if p.botUserID != "" {
 		p.ensureBotProfile()
@@ -93,6 +155,83 @@ func (p *Plugin) ensureBotUser() error {
 	return nil
 }
 
+// OnConfigurationChange validates admin-provided settings so mistakes show up in the server log,
+// and caches the parsed settings that are read on every post.
+func (p *Plugin) OnConfigurationChange() error {
+	steps := defaultSteps
+	if raw := strings.TrimSpace(p.getPluginSetting("ChecklistSteps", "")); raw != "" {
+		parsed, err := parseStepDefinitions(raw)
+		if err != nil {
+			p.API.LogError("Invalid ChecklistSteps setting; falling back to default steps", "err", err.Error())
+		} else {
+			steps = parsed
+		}
+	}
+	var tracks []TrackDefinition
+	if raw := strings.TrimSpace(p.getPluginSetting("OnboardingTracks", "")); raw != "" {
+		parsed, err := parseTrackDefinitions(raw, steps)
+		if err != nil {
+			p.API.LogError("Invalid OnboardingTracks setting; everyone gets the full checklist", "err", err.Error())
+		}
+		tracks = parsed
+	}
+	if raw := strings.TrimSpace(p.getPluginSetting("AutoJoinChannels", "")); raw != "" {
+		if _, err := parseAutoJoinRules(raw); err != nil {
+			p.API.LogError("Invalid AutoJoinChannels setting; no channels are joined automatically", "err", err.Error())
+		}
+	}
+	if raw := strings.TrimSpace(p.getPluginSetting("WelcomeChannelOverrides", "")); raw != "" {
+		if _, err := parseWelcomeChannelOverrides(raw); err != nil {
+			p.API.LogError("Invalid WelcomeChannelOverrides setting; using WelcomeChannel for all teams", "err", err.Error())
+		}
+	}
+	var policies []PolicyDefinition
+	if raw := strings.TrimSpace(p.getPluginSetting("Policies", "")); raw != "" {
+		parsed, err := parsePolicyDefinitions(raw)
+		if err != nil {
+			p.API.LogError("Invalid Policies setting; no policies are shown", "err", err.Error())
+		}
+		policies = parsed
+	}
+	var quizzes []QuizDefinition
+	if raw := strings.TrimSpace(p.getPluginSetting("Quizzes", "")); raw != "" {
+		parsed, err := parseQuizDefinitions(raw, steps)
+		if err != nil {
+			p.API.LogError("Invalid Quizzes setting; no step has a quiz", "err", err.Error())
+		}
+		quizzes = parsed
+	}
+	p.settings.Lock()
+	p.settings.steps = steps
+	p.settings.tracks = tracks
+	p.settings.policies = policies
+	p.settings.quizzes = quizzes
+	p.settings.channelPostChecks = hasChannelPostChecks(steps)
+	p.settings.Unlock()
+
+	// Before activation the bot doesn't exist yet; OnActivate syncs then
+	if p.botUserID != "" {
+		if err := p.syncPolicyVersions(); err != nil {
+			p.API.LogWarn("failed to check policy versions", "err", err.Error())
+		}
+	}
+	return nil
+}
+
+// OnPluginClusterEvent reloads the translations when another node changed an override.
+func (p *Plugin) OnPluginClusterEvent(c *plugin.Context, ev model.PluginClusterEvent) {
+	if ev.Id != clusterEventReloadTranslations {
+		return
+	}
+	if err := p.reloadTranslations(); err != nil {
+		p.API.LogError("failed to reload translations", "err", err.Error())
+		return
+	}
+	if err := p.registerCommands(); err != nil {
+		p.API.LogWarn("failed to re-register commands", "err", err.Error())
+	}
+}
+
 // UserHasBeenCreated is called when a new user is created.
 func (p *Plugin) UserHasBeenCreated(c *plugin.Context, user *model.User) {
 	// Ignore bots
@@ -100,13 +239,150 @@ func (p *Plugin) UserHasBeenCreated(c *plugin.Context, user *model.User) {
 		return
 	}
 
-	if err := p.startOnboardingForUser(user); err != nil {
-		p.API.LogError("Failed to start onboarding", "user_id", user.Id, "err", err.Error())
+	// With OnboardingTeams set, onboarding starts when the user joins one of them
+	if len(p.onboardingTeams()) == 0 {
+		if err := p.startOnboardingForUser(user, ""); err != nil {
+			p.API.LogError("Failed to start onboarding", "user_id", user.Id, "err", err.Error())
+		}
+	}
+
+	p.postTeamWelcomes(user)
+}
+
+// UserHasJoinedTeam is called when a user joins or is added to a team.
+func (p *Plugin) UserHasJoinedTeam(c *plugin.Context, teamMember *model.TeamMember, actor *model.User) {
+	user, appErr := p.API.GetUser(teamMember.UserId)
+	if appErr != nil {
+		p.API.LogError("failed to get user", "user_id", teamMember.UserId, "err", appErr.Error())
+		return
+	}
+	if user.IsBot {
+		return
+	}
+
+	team, appErr := p.API.GetTeam(teamMember.TeamId)
+	if appErr != nil {
+		p.API.LogError("failed to get team", "team_id", teamMember.TeamId, "err", appErr.Error())
+		return
+	}
+
+	if p.shouldStartOnTeamJoin(team) {
+		if err := p.startOnboardingForUser(user, team.Id); err != nil {
+			p.API.LogError("Failed to start onboarding", "user_id", user.Id, "team_id", team.Id, "err", err.Error())
+		}
+	}
+
+	// Team rules of tracks couldn't match while the user had no team
+	if err := p.reselectTrackOnTeamJoin(user); err != nil {
+		p.API.LogWarn("failed to select onboarding track", "user_id", user.Id, "team_id", team.Id, "err", err.Error())
+	}
+
+	// Users often get their account before their first team
+	if err := p.autoJoinOnTeamJoin(user, team); err != nil {
+		p.API.LogWarn("failed to auto-join channels", "user_id", user.Id, "team_id", team.Id, "err", err.Error())
+	}
+
+	if err := p.postTeamWelcome(user, team); err != nil {
+		p.API.LogWarn("failed to post welcome", "user_id", user.Id, "team_id", team.Id, "err", err.Error())
+	}
+}
+
+// UserHasJoinedChannel completes steps that wait for the user to join channels.
+func (p *Plugin) UserHasJoinedChannel(c *plugin.Context, channelMember *model.ChannelMember, actor *model.User) {
+	state, err := p.loadState(channelMember.UserId)
+	if err != nil {
+		p.API.LogWarn("failed to load onboarding state", "user_id", channelMember.UserId, "err", err.Error())
+		return
+	}
+	if state == nil {
+		return
+	}
+	p.verifyUserSteps(state)
+}
+
+// MessageHasBeenPosted remembers posts in channels a step checks for and
+// completes the steps that waited for them.
+func (p *Plugin) MessageHasBeenPosted(c *plugin.Context, post *model.Post) {
+	if post.UserId == p.botUserID || post.IsSystemMessage() {
+		return
+	}
+
+	// Most installations don't check for posts; skip the state lookup then
+	p.settings.RLock()
+	channelPostChecks := p.settings.channelPostChecks
+	p.settings.RUnlock()
+	if !channelPostChecks {
+		return
+	}
+
+	state, err := p.loadState(post.UserId)
+	if err != nil {
+		p.API.LogWarn("failed to load onboarding state", "user_id", post.UserId, "err", err.Error())
+		return
+	}
+	if state == nil || !p.hasOpenCheckedSteps(state) {
+		return
+	}
+
+	channel, appErr := p.API.GetChannel(post.ChannelId)
+	if appErr != nil {
+		p.API.LogWarn("failed to get channel", "channel_id", post.ChannelId, "err", appErr.Error())
+		return
 	}
+	if !p.watchesChannelPosts(state, channel.Name) {
+		return
+	}
+
+	// Search indexing may lag behind; remember the post right away
+	if err := p.recordChannelPost(state, channel.Id); err != nil {
+		p.API.LogError("failed to save onboarding state", "user_id", post.UserId, "err", err.Error())
+		return
+	}
+	p.verifyUserSteps(state)
 }
 
-// ServeHTTP handles interactive button callbacks from posts.
+// onboardingTeams returns the team names listed in the OnboardingTeams setting
+func (p *Plugin) onboardingTeams() []string {
+	var teams []string
+	for _, name := range strings.Split(p.getPluginSetting("OnboardingTeams", ""), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			teams = append(teams, name)
+		}
+	}
+	return teams
+}
+
+// shouldStartOnTeamJoin reports whether joining the team starts onboarding
+// for users who don't have state yet.
+func (p *Plugin) shouldStartOnTeamJoin(team *model.Team) bool {
+	if !p.getPluginBoolSetting("StartOnTeamJoin", true) {
+		return false
+	}
+
+	teams := p.onboardingTeams()
+	if len(teams) == 0 {
+		return true
+	}
+	for _, name := range teams {
+		if strings.EqualFold(name, team.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeHTTP handles interactive button callbacks from posts and the REST
+// API. Every route requires a user authenticated by the server.
 func (p *Plugin) ServeHTTP(c *plugin.Context, w http.ResponseWriter, r *http.Request) {
+	requireUser(p.route).ServeHTTP(w, r)
+}
+
+func (p *Plugin) route(w http.ResponseWriter, r *http.Request, userID string) {
+	if strings.HasPrefix(r.URL.Path, "/api/v1/") {
+		p.serveAPI(w, r, userID)
+		return
+	}
+
 	// Only handle POST integrations from interactive message actions
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -115,9 +391,19 @@ func (p *Plugin) ServeHTTP(c *plugin.Context, w http.ResponseWriter, r *http.Req
 
 	switch r.URL.Path {
 	case "/complete-step":
-		p.handleCompleteStep(w, r)
+		p.handleCompleteStep(w, r, userID)
 	case "/submit-signature":
-		p.handleSignatureSubmission(w, r)
+		p.handleSignatureSubmission(w, r, userID)
+	case "/signature-action":
+		p.handleSignatureAction(w, r, userID)
+	case "/submit-introduction":
+		p.handleIntroductionSubmission(w, r, userID)
+	case "/policy-action":
+		p.handlePolicyAction(w, r, userID)
+	case "/submit-quiz":
+		p.handleQuizSubmission(w, r, userID)
+	case "/submit-template":
+		p.handleTemplateSubmission(w, r, userID)
 	default:
 		w.WriteHeader(http.StatusNotFound)
 	}
@@ -162,7 +448,57 @@ func (p *Plugin) loadState(userID string) (*OnboardingState, error) {
 	return &state, nil
 }
 
-func (p *Plugin) saveState(state *OnboardingState) error {
+// recordChannelPost notes on the state that the user posted in the channel
+func (p *Plugin) recordChannelPost(state *OnboardingState, channelID string) error {
+	return p.updateState(state, func(s *OnboardingState) {
+		if s.PostedChannels == nil {
+			s.PostedChannels = map[string]bool{}
+		}
+		s.PostedChannels[channelID] = true
+	})
+}
+
+// updateState applies update to the stored state with compare-and-set, so
+// hooks firing at the same time don't overwrite each other's changes. update
+// may run more than once. On success state holds the saved result.
+func (p *Plugin) updateState(state *OnboardingState, update func(*OnboardingState)) error {
+	key := onboardingKVPrefix + state.UserID
+	for attempt := 0; attempt < updateStateAttempts; attempt++ {
+		oldData, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return fmt.Errorf("KVGet: %w", appErr)
+		}
+		if oldData == nil {
+			return errStateNotFound
+		}
+
+		var current OnboardingState
+		if err := json.Unmarshal(oldData, &current); err != nil {
+			return err
+		}
+		update(&current)
+		current.LastUpdated = time.Now().UTC()
+
+		newData, err := json.Marshal(&current)
+		if err != nil {
+			return err
+		}
+
+		ok, appErr := p.API.KVCompareAndSet(key, oldData, newData)
+		if appErr != nil {
+			return fmt.Errorf("KVCompareAndSet: %w", appErr)
+		}
+		if ok {
+			*state = current
+			return nil
+		}
+	}
+
+	return fmt.Errorf("update onboarding state: too many concurrent updates")
+}
+
+// createState stores a new state only if the user has none yet
+func (p *Plugin) createState(state *OnboardingState) (bool, error) {
 	state.LastUpdated = time.Now().UTC()
 	if state.StartedAt.IsZero() {
 		state.StartedAt = state.LastUpdated
@@ -170,16 +506,48 @@ func (p *Plugin) saveState(state *OnboardingState) error {
 
 	b, err := json.Marshal(state)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	key := onboardingKVPrefix + state.UserID
-	if appErr := p.API.KVSet(key, b); appErr != nil {
+	created, appErr := p.API.KVSetWithOptions(key, b, model.PluginKVSetOptions{Atomic: true, OldValue: nil})
+	if appErr != nil {
+		return false, appErr
+	}
+	return created, nil
+}
+
+func (p *Plugin) deleteState(userID string) error {
+	if appErr := p.API.KVDelete(onboardingKVPrefix + userID); appErr != nil {
+		return appErr
+	}
+	if appErr := p.API.KVDelete(onboardingReminderKVPrefix + userID); appErr != nil {
 		return appErr
 	}
 	return nil
 }
 
+// listStateUserIDs returns the ids of all users with onboarding state
+func (p *Plugin) listStateUserIDs() ([]string, error) {
+	const perPage = 200
+
+	var userIDs []string
+	for page := 0; ; page++ {
+		keys, appErr := p.API.KVList(page, perPage)
+		if appErr != nil {
+			return nil, fmt.Errorf("KVList: %w", appErr)
+		}
+		for _, key := range keys {
+			if strings.HasPrefix(key, onboardingKVPrefix) {
+				userIDs = append(userIDs, strings.TrimPrefix(key, onboardingKVPrefix))
+			}
+		}
+		if len(keys) < perPage {
+			return userIDs, nil
+		}
+	}
+}
+
 func (p *Plugin) loadBotUserID() (string, error) {
 	data, appErr := p.API.KVGet(botUserKVKey)
 	if appErr != nil {
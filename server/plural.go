@@ -0,0 +1,195 @@
+package main
+
+// CLDR plural categories
+const (
+	pluralZero  = "zero"
+	pluralOne   = "one"
+	pluralTwo   = "two"
+	pluralFew   = "few"
+	pluralMany  = "many"
+	pluralOther = "other"
+)
+
+// pluralRule is a language's CLDR cardinal plural rule, restricted to the
+// non-negative integers the plugin counts with
+type pluralRule struct {
+	categories []string
+	category   func(n int) string
+}
+
+var (
+	// en, de, nl, sv, tr, ...: 1 step, 2 steps
+	pluralRuleOneOther = pluralRule{
+		categories: []string{pluralOne, pluralOther},
+		category: func(n int) string {
+			if n == 1 {
+				return pluralOne
+			}
+			return pluralOther
+		},
+	}
+	// fa, hi: 0 and 1 are singular
+	pluralRuleZeroIsOne = pluralRule{
+		categories: []string{pluralOne, pluralOther},
+		category: func(n int) string {
+			if n == 0 || n == 1 {
+				return pluralOne
+			}
+			return pluralOther
+		},
+	}
+	// ja, ko, zh, ...: no plural forms
+	pluralRuleOtherOnly = pluralRule{
+		categories: []string{pluralOther},
+		category:   func(n int) string { return pluralOther },
+	}
+)
+
+// pluralRules maps language codes to their plural rule. Languages that
+// aren't listed use the English rule.
+var pluralRules = map[string]pluralRule{
+	"en": pluralRuleOneOther,
+	"de": pluralRuleOneOther,
+	"nl": pluralRuleOneOther,
+	"sv": pluralRuleOneOther,
+	"da": pluralRuleOneOther,
+	"nb": pluralRuleOneOther,
+	"fi": pluralRuleOneOther,
+	"el": pluralRuleOneOther,
+	"tr": pluralRuleOneOther,
+	"fa": pluralRuleZeroIsOne,
+	"hi": pluralRuleZeroIsOne,
+	"ja": pluralRuleOtherOnly,
+	"ko": pluralRuleOtherOnly,
+	"zh": pluralRuleOtherOnly,
+	"vi": pluralRuleOtherOnly,
+	"id": pluralRuleOtherOnly,
+	"th": pluralRuleOtherOnly,
+	// fr, pt: 0 and 1 are singular; whole millions take "de"
+	"fr": {
+		categories: []string{pluralOne, pluralMany, pluralOther},
+		category: func(n int) string {
+			switch {
+			case n == 0 || n == 1:
+				return pluralOne
+			case n%1000000 == 0:
+				return pluralMany
+			}
+			return pluralOther
+		},
+	},
+	"pt": {
+		categories: []string{pluralOne, pluralMany, pluralOther},
+		category: func(n int) string {
+			switch {
+			case n == 0 || n == 1:
+				return pluralOne
+			case n%1000000 == 0:
+				return pluralMany
+			}
+			return pluralOther
+		},
+	},
+	// es, it: whole millions take "de" / "di"
+	"es": {
+		categories: []string{pluralOne, pluralMany, pluralOther},
+		category: func(n int) string {
+			switch {
+			case n == 1:
+				return pluralOne
+			case n != 0 && n%1000000 == 0:
+				return pluralMany
+			}
+			return pluralOther
+		},
+	},
+	"it": {
+		categories: []string{pluralOne, pluralMany, pluralOther},
+		category: func(n int) string {
+			switch {
+			case n == 1:
+				return pluralOne
+			case n != 0 && n%1000000 == 0:
+				return pluralMany
+			}
+			return pluralOther
+		},
+	},
+	"ar": {
+		categories: []string{pluralZero, pluralOne, pluralTwo, pluralFew, pluralMany, pluralOther},
+		category: func(n int) string {
+			switch {
+			case n == 0:
+				return pluralZero
+			case n == 1:
+				return pluralOne
+			case n == 2:
+				return pluralTwo
+			case n%100 >= 3 && n%100 <= 10:
+				return pluralFew
+			case n%100 >= 11:
+				return pluralMany
+			}
+			return pluralOther
+		},
+	},
+	"ru": pluralRuleSlavic,
+	"uk": pluralRuleSlavic,
+	"pl": {
+		categories: []string{pluralOne, pluralFew, pluralMany, pluralOther},
+		category: func(n int) string {
+			switch {
+			case n == 1:
+				return pluralOne
+			case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+				return pluralFew
+			}
+			return pluralMany
+		},
+	},
+}
+
+// ru, uk: 1, 21, 31 … take "one"; 2–4, 22–24 … take "few"
+var pluralRuleSlavic = pluralRule{
+	categories: []string{pluralOne, pluralFew, pluralMany, pluralOther},
+	category: func(n int) string {
+		switch {
+		case n%10 == 1 && n%100 != 11:
+			return pluralOne
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return pluralFew
+		}
+		return pluralMany
+	},
+}
+
+// pluralRuleFor returns the plural rule of a language
+func pluralRuleFor(language string) pluralRule {
+	if rule, ok := pluralRules[language]; ok {
+		return rule
+	}
+	return pluralRuleOneOther
+}
+
+// PluralMessage holds one format string per plural category. In a catalog
+// each form is its own key: "<ID>_one", "<ID>_other", ...
+type PluralMessage struct {
+	language string
+	forms    map[string]string
+}
+
+// Select returns the format string to use for the count n
+func (m PluralMessage) Select(n int) string {
+	if n < 0 {
+		n = -n
+	}
+	if form, ok := m.forms[pluralRuleFor(m.language).category(n)]; ok {
+		return form
+	}
+	return m.forms[pluralOther]
+}
+
+// pluralKey returns the catalog key of one plural form of a message
+func pluralKey(id, category string) string {
+	return id + "_" + category
+}
@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+func TestPluralCategories(t *testing.T) {
+	tests := []struct {
+		language string
+		want     map[int]string
+	}{
+		{language: "en", want: map[int]string{0: pluralOther, 1: pluralOne, 2: pluralOther, 21: pluralOther}},
+		{language: "de", want: map[int]string{0: pluralOther, 1: pluralOne, 5: pluralOther}},
+		{language: "hi", want: map[int]string{0: pluralOne, 1: pluralOne, 2: pluralOther}},
+		{language: "ja", want: map[int]string{0: pluralOther, 1: pluralOther, 2: pluralOther}},
+		{language: "fr", want: map[int]string{0: pluralOne, 1: pluralOne, 2: pluralOther, 1000000: pluralMany, 2000000: pluralMany, 1000001: pluralOther}},
+		{language: "es", want: map[int]string{0: pluralOther, 1: pluralOne, 2: pluralOther, 1000000: pluralMany}},
+		{language: "ar", want: map[int]string{0: pluralZero, 1: pluralOne, 2: pluralTwo, 3: pluralFew, 10: pluralFew, 11: pluralMany, 99: pluralMany, 100: pluralOther, 102: pluralOther, 103: pluralFew}},
+		{language: "ru", want: map[int]string{1: pluralOne, 2: pluralFew, 4: pluralFew, 5: pluralMany, 11: pluralMany, 12: pluralMany, 21: pluralOne, 22: pluralFew, 111: pluralMany, 0: pluralMany}},
+		{language: "pl", want: map[int]string{1: pluralOne, 2: pluralFew, 5: pluralMany, 12: pluralMany, 21: pluralMany, 22: pluralFew, 0: pluralMany}},
+		// Languages without a rule fall back to English
+		{language: "xx", want: map[int]string{1: pluralOne, 2: pluralOther}},
+	}
+	for _, test := range tests {
+		rule := pluralRuleFor(test.language)
+		for n, want := range test.want {
+			got := rule.category(n)
+			if got != want {
+				t.Errorf("%s: category(%d) = %s, want %s", test.language, n, got, want)
+			}
+			if !containsString(rule.categories, got) {
+				t.Errorf("%s: category(%d) = %s is not among the rule's categories %v", test.language, n, got, rule.categories)
+			}
+		}
+	}
+}
+
+func TestPluralMessageSelect(t *testing.T) {
+	message := PluralMessage{
+		language: "ru",
+		forms: map[string]string{
+			pluralOne:   "%d шаг",
+			pluralFew:   "%d шага",
+			pluralOther: "%d шагов",
+		},
+	}
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{n: 1, want: "%d шаг"},
+		{n: 3, want: "%d шага"},
+		{n: -3, want: "%d шага"},
+		// The catalog has no "many" form, so "other" is used
+		{n: 5, want: "%d шагов"},
+	}
+	for _, test := range tests {
+		if got := message.Select(test.n); got != test.want {
+			t.Errorf("Select(%d) = %q, want %q", test.n, got, test.want)
+		}
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,586 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	policyAckKVPrefix   = "onboarding:policy_ack:"
+	policyVersionsKVKey = "onboarding:policy_versions"
+
+	// policyTargetSeparator joins policy id and version in signed button
+	// contexts, so a click always acknowledges the version it showed
+	policyTargetSeparator = "@"
+
+	// policyUpdatePostProp marks the DMs that ask for a changed policy to be
+	// acknowledged again; its value is the policy id
+	policyUpdatePostProp = "onboarding_policy_update"
+)
+
+// PolicyDefinition is one document of the policy registry
+type PolicyDefinition struct {
+	ID      string        `json:"id"`
+	Title   LocalizedText `json:"title"`
+	URL     string        `json:"url"`
+	Version string        `json:"version"`
+	// Tracks limits the policy to users of these tracks; empty means everyone
+	Tracks []string `json:"tracks,omitempty"`
+}
+
+// PolicyAcknowledgement records that a user accepted a policy version. The
+// per-user list is only ever appended to.
+type PolicyAcknowledgement struct {
+	PolicyID       string    `json:"policy_id"`
+	Version        string    `json:"version"`
+	AcknowledgedAt time.Time `json:"acknowledged_at"`
+}
+
+// parsePolicyDefinitions parses and validates the Policies setting
+func parsePolicyDefinitions(raw string) ([]PolicyDefinition, error) {
+	var policies []PolicyDefinition
+	if err := json.Unmarshal([]byte(raw), &policies); err != nil {
+		return nil, fmt.Errorf("parse policies: %w", err)
+	}
+
+	seen := make(map[string]struct{}, len(policies))
+	for i, policy := range policies {
+		if strings.TrimSpace(policy.ID) == "" {
+			return nil, fmt.Errorf("policy %d: id is required", i+1)
+		}
+		if strings.Contains(policy.ID, policyTargetSeparator) {
+			return nil, fmt.Errorf("policy %q: id must not contain %q", policy.ID, policyTargetSeparator)
+		}
+		if _, ok := seen[policy.ID]; ok {
+			return nil, fmt.Errorf("policy %q: duplicate id", policy.ID)
+		}
+		seen[policy.ID] = struct{}{}
+
+		if policy.Title.Get("en") == "" {
+			return nil, fmt.Errorf("policy %q: title is required", policy.ID)
+		}
+		if policy.URL == "" {
+			return nil, fmt.Errorf("policy %q: url is required", policy.ID)
+		}
+		if strings.TrimSpace(policy.Version) == "" {
+			return nil, fmt.Errorf("policy %q: version is required", policy.ID)
+		}
+	}
+	return policies, nil
+}
+
+// getPolicies returns the policy registry parsed in OnConfigurationChange. An
+// empty or invalid setting means there are no policies to acknowledge.
+func (p *Plugin) getPolicies() []PolicyDefinition {
+	p.settings.RLock()
+	defer p.settings.RUnlock()
+	return p.settings.policies
+}
+
+// findPolicy looks up a policy by its id
+func findPolicy(policies []PolicyDefinition, id string) (PolicyDefinition, bool) {
+	for _, policy := range policies {
+		if policy.ID == id {
+			return policy, true
+		}
+	}
+	return PolicyDefinition{}, false
+}
+
+// policiesForState returns the policies that apply to the user's track
+func (p *Plugin) policiesForState(state *OnboardingState) []PolicyDefinition {
+	var policies []PolicyDefinition
+	for _, policy := range p.getPolicies() {
+		if policyAppliesTo(policy, state) {
+			policies = append(policies, policy)
+		}
+	}
+	return policies
+}
+
+// policyAppliesTo reports whether the policy is required for the user's track
+func policyAppliesTo(policy PolicyDefinition, state *OnboardingState) bool {
+	if len(policy.Tracks) == 0 {
+		return true
+	}
+	return state != nil && containsFold(policy.Tracks, state.Track)
+}
+
+// policyTarget is the signed button payload for acknowledging a policy version
+func policyTarget(policy PolicyDefinition) string {
+	return policy.ID + policyTargetSeparator + policy.Version
+}
+
+// currentAcknowledgement returns the user's acknowledgement of the policy's
+// current version, if any
+func currentAcknowledgement(acks []PolicyAcknowledgement, policy PolicyDefinition) (PolicyAcknowledgement, bool) {
+	for i := len(acks) - 1; i >= 0; i-- {
+		if acks[i].PolicyID == policy.ID && acks[i].Version == policy.Version {
+			return acks[i], true
+		}
+	}
+	return PolicyAcknowledgement{}, false
+}
+
+// pendingPolicies returns the policies of the user's track whose current
+// version the user hasn't acknowledged
+func (p *Plugin) pendingPolicies(state *OnboardingState) ([]PolicyDefinition, error) {
+	policies := p.policiesForState(state)
+	if len(policies) == 0 {
+		return nil, nil
+	}
+
+	acks, err := p.loadPolicyAcknowledgements(state.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []PolicyDefinition
+	for _, policy := range policies {
+		if _, ok := currentAcknowledgement(acks, policy); !ok {
+			pending = append(pending, policy)
+		}
+	}
+	return pending, nil
+}
+
+// loadPolicyAcknowledgements returns the user's acknowledgements, oldest first
+func (p *Plugin) loadPolicyAcknowledgements(userID string) ([]PolicyAcknowledgement, error) {
+	data, appErr := p.API.KVGet(policyAckKVPrefix + userID)
+	if appErr != nil {
+		return nil, fmt.Errorf("KVGet: %w", appErr)
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var acks []PolicyAcknowledgement
+	if err := json.Unmarshal(data, &acks); err != nil {
+		return nil, err
+	}
+	return acks, nil
+}
+
+// appendPolicyAcknowledgement records an acknowledgement. Like the step
+// history, the list is appended to with compare-and-set.
+func (p *Plugin) appendPolicyAcknowledgement(userID string, ack PolicyAcknowledgement) error {
+	if ack.AcknowledgedAt.IsZero() {
+		ack.AcknowledgedAt = time.Now().UTC()
+	}
+
+	key := policyAckKVPrefix + userID
+	for attempt := 0; attempt < appendEventAttempts; attempt++ {
+		oldData, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return fmt.Errorf("KVGet: %w", appErr)
+		}
+
+		var acks []PolicyAcknowledgement
+		if oldData != nil {
+			if err := json.Unmarshal(oldData, &acks); err != nil {
+				return err
+			}
+		}
+		acks = append(acks, ack)
+
+		newData, err := json.Marshal(acks)
+		if err != nil {
+			return err
+		}
+
+		ok, appErr := p.API.KVCompareAndSet(key, oldData, newData)
+		if appErr != nil {
+			return fmt.Errorf("KVCompareAndSet: %w", appErr)
+		}
+		if ok {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("append policy acknowledgement: too many concurrent updates")
+}
+
+// renderPolicyLines lists the policies of the user's track with their status,
+// for the text of steps with the policies action
+func (p *Plugin) renderPolicyLines(state *OnboardingState, tr Translations, language string) string {
+	policies := p.policiesForState(state)
+	if len(policies) == 0 {
+		return ""
+	}
+
+	acks, err := p.loadPolicyAcknowledgements(state.UserID)
+	if err != nil {
+		p.API.LogWarn("failed to load policy acknowledgements", "user_id", state.UserID, "err", err.Error())
+	}
+
+	lines := make([]string, 0, len(policies))
+	for _, policy := range policies {
+		line := fmt.Sprintf(tr.PolicyLine, policy.Title.Get(language), policy.URL, policy.Version)
+		ack, ok := currentAcknowledgement(acks, policy)
+		if ok {
+			line += fmt.Sprintf(tr.PolicyAcceptedOn, ack.AcknowledgedAt.Format("2006-01-02"))
+		}
+		lines = append(lines, "- "+checkbox(ok)+line)
+	}
+	return "\n\n" + strings.Join(lines, "\n")
+}
+
+// buildPolicyActions returns an accept button for each pending policy
+func (p *Plugin) buildPolicyActions(state *OnboardingState, postID, callbackURL string, tr Translations, language string) []*model.PostAction {
+	pending, err := p.pendingPolicies(state)
+	if err != nil {
+		p.API.LogWarn("failed to get pending policies", "user_id", state.UserID, "err", err.Error())
+		return nil
+	}
+
+	actions := make([]*model.PostAction, 0, len(pending))
+	for _, policy := range pending {
+		actions = append(actions, p.policyAction(state.UserID, postID, callbackURL, policy, tr, language))
+	}
+	return actions
+}
+
+func (p *Plugin) policyAction(userID, postID, callbackURL string, policy PolicyDefinition, tr Translations, language string) *model.PostAction {
+	return &model.PostAction{
+		Name:  fmt.Sprintf(tr.ButtonAcknowledgePolicy, policy.Title.Get(language)),
+		Type:  model.PostActionTypeButton,
+		Style: "primary",
+		Integration: &model.PostActionIntegration{
+			URL:     callbackURL,
+			Context: p.signActionContext(userID, postID, actionAcknowledgePolicy, policyTarget(policy)),
+		},
+	}
+}
+
+// handlePolicyAction records a click on an accept button, in the checklist
+// or in a policy update DM
+func (p *Plugin) handlePolicyAction(w http.ResponseWriter, r *http.Request, userID string) {
+	var req model.PostActionIntegrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		p.API.LogError("failed to decode integration request", "err", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !p.verifyActingUser(w, r, userID, req.UserId) {
+		return
+	}
+
+	state, err := p.loadState(userID)
+	if err != nil {
+		p.API.LogError("failed to load onboarding state", "user_id", userID, "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	onChecklist := state != nil && state.ChecklistPostID == req.PostId
+
+	language := p.languageForUser(userID)
+	tr := p.translationsFor(language)
+
+	// Apart from the current checklist, only update DMs take clicks. Buttons
+	// of older checklist posts must not replace that checklist.
+	var updatePost *model.Post
+	if !onChecklist {
+		if updatePost = p.getPolicyUpdatePost(userID, req.PostId); updatePost == nil {
+			p.writeIntegrationResponse(w, &model.PostActionIntegrationResponse{EphemeralText: tr.ErrorChecklistOutdated})
+			return
+		}
+	}
+
+	// respond re-renders the post the button was on
+	respond := func(ephemeralText string) {
+		if onChecklist {
+			p.respondWithChecklist(w, &req, state, ephemeralText)
+			return
+		}
+		p.writeIntegrationResponse(w, &model.PostActionIntegrationResponse{EphemeralText: ephemeralText})
+	}
+
+	action, target, err := p.verifyActionContext(userID, req.PostId, req.Context)
+	if errors.Is(err, errActionSignatureExpired) || errors.Is(err, errActionSignatureMissing) {
+		if !onChecklist {
+			// The unverified target only picks the policy of the new button
+			expiredTarget, _ := req.Context[contextKeyStep].(string)
+			p.resignPolicyUpdate(updatePost, userID, expiredTarget)
+		}
+		respond(tr.ErrorActionExpired)
+		return
+	}
+	if err != nil {
+		p.API.LogWarn("rejected button click with invalid signature", "user_id", userID, "post_id", req.PostId)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if action != actionAcknowledgePolicy {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	policyID, version, _ := strings.Cut(target, policyTargetSeparator)
+	policy, ok := findPolicy(p.getPolicies(), policyID)
+	if !ok || policy.Version != version {
+		respond(tr.ErrorPolicyOutdated)
+		return
+	}
+
+	acks, err := p.loadPolicyAcknowledgements(userID)
+	if err != nil {
+		p.API.LogError("failed to load policy acknowledgements", "user_id", userID, "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	ack, acknowledged := currentAcknowledgement(acks, policy)
+	if !acknowledged {
+		ack = PolicyAcknowledgement{PolicyID: policy.ID, Version: policy.Version, AcknowledgedAt: time.Now().UTC()}
+		if err := p.appendPolicyAcknowledgement(userID, ack); err != nil {
+			p.API.LogError("failed to save policy acknowledgement", "user_id", userID, "policy", policy.ID, "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Accepting the last pending policy may complete the step
+	if state != nil {
+		if user, appErr := p.API.GetUser(userID); appErr == nil {
+			p.verifyOpenSteps(user, state)
+			if !onChecklist {
+				p.refreshChecklistPost(user, state)
+			}
+		}
+	}
+
+	message := fmt.Sprintf(tr.PolicyAcknowledged, policy.Title.Get(language), policy.Version)
+	if onChecklist {
+		respond(message)
+		return
+	}
+
+	// Replace the button of the update DM with the acknowledgement
+	updatePost.AddProp("attachments", []*model.SlackAttachment{{
+		Text: fmt.Sprintf(tr.PolicyUpdateAccepted, policy.Version, ack.AcknowledgedAt.Format("2006-01-02")),
+	}})
+	p.writeIntegrationResponse(w, &model.PostActionIntegrationResponse{Update: updatePost, EphemeralText: message})
+}
+
+// getPolicyUpdatePost returns the post if it is a policy update DM from the
+// bot to the user, or nil otherwise
+func (p *Plugin) getPolicyUpdatePost(userID, postID string) *model.Post {
+	if postID == "" {
+		return nil
+	}
+	channel, appErr := p.API.GetDirectChannel(p.botUserID, userID)
+	if appErr != nil {
+		return nil
+	}
+	post, appErr := p.API.GetPost(postID)
+	if appErr != nil || post.UserId != p.botUserID || post.ChannelId != channel.Id {
+		return nil
+	}
+	if policyID, _ := post.GetProp(policyUpdatePostProp).(string); policyID == "" {
+		return nil
+	}
+	return post
+}
+
+// resignPolicyUpdate renews the expired button of a policy update DM
+func (p *Plugin) resignPolicyUpdate(post *model.Post, userID, target string) {
+	policyID, _, _ := strings.Cut(target, policyTargetSeparator)
+	policy, ok := findPolicy(p.getPolicies(), policyID)
+	if !ok || post.GetProp(policyUpdatePostProp) != policy.ID {
+		return
+	}
+	if err := p.attachPolicyButton(post, userID, policy); err != nil {
+		p.API.LogWarn("failed to update policy update post", "post_id", post.Id, "err", err.Error())
+	}
+}
+
+func (p *Plugin) attachPolicyButton(post *model.Post, userID string, policy PolicyDefinition) error {
+	pluginURL, err := p.pluginURL()
+	if err != nil {
+		return err
+	}
+	language := p.languageForUser(userID)
+	tr := p.translationsFor(language)
+
+	post.AddProp("attachments", []*model.SlackAttachment{{
+		Actions: []*model.PostAction{p.policyAction(userID, post.Id, pluginURL+"/policy-action", policy, tr, language)},
+	}})
+	if _, appErr := p.API.UpdatePost(post); appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+// syncPolicyVersions compares the configured policy versions with the ones
+// seen last and asks users to acknowledge changed policies again. The
+// snapshot is swapped with compare-and-set, so only one node sends the DMs.
+func (p *Plugin) syncPolicyVersions() error {
+	// An invalid setting must not replace the snapshot, or fixing it later
+	// wouldn't be noticed as a version change
+	var policies []PolicyDefinition
+	if raw := strings.TrimSpace(p.getPluginSetting("Policies", "")); raw != "" {
+		var err error
+		if policies, err = parsePolicyDefinitions(raw); err != nil {
+			return err
+		}
+	}
+
+	versions := make(map[string]string, len(policies))
+	for _, policy := range policies {
+		versions[policy.ID] = policy.Version
+	}
+
+	oldData, appErr := p.API.KVGet(policyVersionsKVKey)
+	if appErr != nil {
+		return fmt.Errorf("KVGet: %w", appErr)
+	}
+	var previous map[string]string
+	if oldData != nil {
+		if err := json.Unmarshal(oldData, &previous); err != nil {
+			return err
+		}
+	}
+
+	var changed []PolicyDefinition
+	for _, policy := range policies {
+		if version, ok := previous[policy.ID]; ok && version != policy.Version {
+			changed = append(changed, policy)
+		}
+	}
+	if oldData != nil && len(changed) == 0 && len(previous) == len(versions) {
+		return nil
+	}
+
+	newData, err := json.Marshal(versions)
+	if err != nil {
+		return err
+	}
+	ok, appErr := p.API.KVCompareAndSet(policyVersionsKVKey, oldData, newData)
+	if appErr != nil {
+		return fmt.Errorf("KVCompareAndSet: %w", appErr)
+	}
+	if !ok || len(changed) == 0 {
+		return nil
+	}
+
+	go p.requestPolicyAcknowledgements(changed)
+	return nil
+}
+
+// requestPolicyAcknowledgements DMs everyone who accepted an older version of
+// a changed policy
+func (p *Plugin) requestPolicyAcknowledgements(changed []PolicyDefinition) {
+	const perPage = 200
+
+	for page := 0; ; page++ {
+		keys, appErr := p.API.KVList(page, perPage)
+		if appErr != nil {
+			p.API.LogError("failed to list policy acknowledgements", "err", appErr.Error())
+			return
+		}
+		for _, key := range keys {
+			if !strings.HasPrefix(key, policyAckKVPrefix) {
+				continue
+			}
+			userID := strings.TrimPrefix(key, policyAckKVPrefix)
+			if err := p.requestUserPolicyAcknowledgements(userID, changed); err != nil {
+				p.API.LogWarn("failed to request policy acknowledgement", "user_id", userID, "err", err.Error())
+			}
+		}
+		if len(keys) < perPage {
+			return
+		}
+	}
+}
+
+func (p *Plugin) requestUserPolicyAcknowledgements(userID string, changed []PolicyDefinition) error {
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		return appErr
+	}
+	if user.DeleteAt != 0 || user.IsBot {
+		return nil
+	}
+
+	acks, err := p.loadPolicyAcknowledgements(userID)
+	if err != nil {
+		return err
+	}
+	state, err := p.loadState(userID)
+	if err != nil {
+		return err
+	}
+
+	language := p.resolveLanguage(user, state)
+	tr := p.translationsFor(language)
+
+	channel, appErr := p.API.GetDirectChannel(p.botUserID, userID)
+	if appErr != nil {
+		return appErr
+	}
+
+	reopen := false
+	for _, policy := range changed {
+		if !policyAppliesTo(policy, state) {
+			continue
+		}
+		if !hasAcknowledgedPolicy(acks, policy.ID) {
+			continue
+		}
+		if _, ok := currentAcknowledgement(acks, policy); ok {
+			continue
+		}
+		reopen = true
+
+		created, appErr := p.API.CreatePost(&model.Post{
+			UserId:    p.botUserID,
+			ChannelId: channel.Id,
+			Message:   fmt.Sprintf(tr.PolicyUpdatedMessage, policy.Title.Get(language), policy.Version, policy.URL),
+			Props:     model.StringInterface{policyUpdatePostProp: policy.ID},
+		})
+		if appErr != nil {
+			return appErr
+		}
+		// Button contexts are signed for the post id, which only exists now
+		if err := p.attachPolicyButton(created, userID, policy); err != nil {
+			return err
+		}
+	}
+
+	if state == nil {
+		return nil
+	}
+	if reopen {
+		p.reopenPolicySteps(state)
+	}
+	p.refreshChecklistPost(user, state)
+	return nil
+}
+
+// reopenPolicySteps unchecks the completed policies steps, so the user has to
+// accept the new versions before the step counts as done again
+func (p *Plugin) reopenPolicySteps(state *OnboardingState) {
+	for _, step := range p.stepsForState(state) {
+		if !hasStepAction(step, stepActionPolicies) || !state.CompletedSteps[step.ID] {
+			continue
+		}
+		if err := p.setStepCompleted(state, step.ID, false, p.botUserID, eventSourcePolicyUpdate); err != nil {
+			p.API.LogError("failed to reopen policy step", "user_id", state.UserID, "step", step.ID, "err", err.Error())
+		}
+	}
+}
+
+// hasAcknowledgedPolicy reports whether the user accepted any version
+func hasAcknowledgedPolicy(acks []PolicyAcknowledgement, policyID string) bool {
+	for _, ack := range acks {
+		if ack.PolicyID == policyID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// pronounsCustom is the select value for pronouns typed by the user
+const pronounsCustom = "custom"
+
+// maxCustomPronounsLength limits typed pronouns
+const maxCustomPronounsLength = 60
+
+var errCustomPronounsInvalid = errors.New("invalid custom pronouns")
+
+// pronounRule says how a pronoun option renders in signatures
+type pronounRule int
+
+const (
+	// pronounRulePair renders "Pronomen <de> - pronouns <en>"
+	pronounRulePair pronounRule = iota
+	// pronounRuleFixed renders the option's German and English text as a
+	// fixed phrase, "<de> / <en>"
+	pronounRuleFixed
+)
+
+// pronounOption is one choice of the pronoun select
+type pronounOption struct {
+	ID      string
+	German  string
+	English string
+	Rule    pronounRule
+}
+
+// pronounOptions are the common bilingual pronoun pairs, in the order the
+// dialog lists them
+var pronounOptions = []pronounOption{
+	{ID: "sie", German: "sie/ihr", English: "she/her", Rule: pronounRulePair},
+	{ID: "er", German: "er/ihm", English: "he/him", Rule: pronounRulePair},
+	{ID: "dey", German: "dey/denen", English: "they/them", Rule: pronounRulePair},
+	{ID: "sie-dey", German: "sie/dey", English: "she/they", Rule: pronounRulePair},
+	{ID: "er-dey", German: "er/dey", English: "he/they", Rule: pronounRulePair},
+	{ID: "alle", German: "Alle Pronomen", English: "Any Pronouns", Rule: pronounRuleFixed},
+	{ID: "keine", German: "Keine Pronomen", English: "No Pronouns", Rule: pronounRuleFixed},
+}
+
+// findPronounOption looks up an option by id
+func findPronounOption(id string) (pronounOption, bool) {
+	for _, option := range pronounOptions {
+		if option.ID == id {
+			return option, true
+		}
+	}
+	return pronounOption{}, false
+}
+
+// Label is the option's text in the dialog select
+func (o pronounOption) Label() string {
+	return o.German + " – " + o.English
+}
+
+// render formats the option by its rule
+func (o pronounOption) render() string {
+	switch o.Rule {
+	case pronounRuleFixed:
+		return o.German + " / " + o.English
+	default:
+		return fmt.Sprintf("Pronomen %s - pronouns %s", o.German, o.English)
+	}
+}
+
+// parseCustomPronouns splits typed pronouns into a German and an English
+// part. "xier/xiem / xe/xem" has both; "xe/xem" is used for both languages.
+func parseCustomPronouns(text string) (pronounOption, error) {
+	text = strings.TrimSpace(text)
+	if text == "" || len(text) > maxCustomPronounsLength || strings.ContainsAny(text, "\n\r<>") {
+		return pronounOption{}, errCustomPronounsInvalid
+	}
+
+	parts := strings.Split(text, " / ")
+	switch len(parts) {
+	case 1:
+		return pronounOption{German: text, English: text, Rule: pronounRulePair}, nil
+	case 2:
+		german, english := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if german == "" || english == "" {
+			return pronounOption{}, errCustomPronounsInvalid
+		}
+		// Typed versions of the fixed phrases render like the options
+		for _, option := range pronounOptions {
+			if option.Rule == pronounRuleFixed && strings.EqualFold(german, option.German) && strings.EqualFold(english, option.English) {
+				return option, nil
+			}
+		}
+		return pronounOption{German: german, English: english, Rule: pronounRulePair}, nil
+	default:
+		return pronounOption{}, errCustomPronounsInvalid
+	}
+}
+
+// resolvePronouns returns the pronoun option of a submission. Submissions from
+// before the select stored free text in Pronouns; it's treated as custom.
+func resolvePronouns(data SignatureData) (pronounOption, bool) {
+	switch data.Pronouns {
+	case "":
+		return pronounOption{}, false
+	case pronounsCustom:
+		option, err := parseCustomPronouns(data.CustomPronouns)
+		return option, err == nil
+	}
+
+	if option, ok := findPronounOption(data.Pronouns); ok {
+		return option, true
+	}
+	option, err := parseCustomPronouns(data.Pronouns)
+	return option, err == nil
+}
+
+// formatPronouns renders the pronoun line of a signature:
+// "sie" -> "Pronomen sie/ihr - pronouns she/her"
+// "keine" -> "Keine Pronomen / No Pronouns"
+// custom "xier/xiem / xe/xem" -> "Pronomen xier/xiem - pronouns xe/xem"
+func formatPronouns(data SignatureData) string {
+	option, ok := resolvePronouns(data)
+	if !ok {
+		return ""
+	}
+	if option.German == option.English && option.Rule == pronounRulePair {
+		return "Pronomen/pronouns " + option.German
+	}
+	return option.render()
+}
+
+// shortPronouns is the compact form used in the Mattermost profile,
+// "sie/ihr · she/her"
+func shortPronouns(data SignatureData) string {
+	option, ok := resolvePronouns(data)
+	if !ok {
+		return ""
+	}
+	if option.German == option.English {
+		return option.German
+	}
+	return option.German + " · " + option.English
+}
+
+// pronounDialogElements returns the pronoun select and the custom pronoun
+// field of a dialog, pre-filled with a stored choice
+func pronounDialogElements(tr Translations, pronouns, customPronouns string) []model.DialogElement {
+	selectOptions := make([]*model.PostActionOptions, 0, len(pronounOptions)+1)
+	for _, option := range pronounOptions {
+		selectOptions = append(selectOptions, &model.PostActionOptions{Text: option.Label(), Value: option.ID})
+	}
+	selectOptions = append(selectOptions, &model.PostActionOptions{Text: tr.DialogPronounsCustomOption, Value: pronounsCustom})
+
+	// Free text from before the select existed pre-fills the custom field
+	if _, known := findPronounOption(pronouns); pronouns != "" && pronouns != pronounsCustom && !known {
+		pronouns, customPronouns = pronounsCustom, pronouns
+	}
+
+	return []model.DialogElement{
+		{
+			DisplayName: tr.DialogPronouns,
+			Name:        "pronouns",
+			Type:        "select",
+			Placeholder: tr.DialogPronounsPlaceholder,
+			Default:     pronouns,
+			Options:     selectOptions,
+			Optional:    true,
+			HelpText:    tr.DialogPronounsHelp,
+		},
+		{
+			DisplayName: tr.DialogCustomPronouns,
+			Name:        "custom_pronouns",
+			Type:        "text",
+			Placeholder: tr.DialogCustomPronounsPlaceholder,
+			Default:     customPronouns,
+			Optional:    true,
+			MaxLength:   maxCustomPronounsLength,
+			HelpText:    tr.DialogCustomPronounsHelp,
+		},
+	}
+}
+
+// readPronounsSubmission reads the fields of pronounDialogElements. Pronouns
+// are an option of the rule table, or typed when "custom" is chosen; typing
+// without choosing counts as custom too. Problems are added to fieldErrors.
+func readPronounsSubmission(submission map[string]interface{}, tr Translations, fieldErrors map[string]string) (string, string) {
+	pronouns, _ := submission["pronouns"].(string)
+	customPronouns, _ := submission["custom_pronouns"].(string)
+
+	customPronouns = strings.TrimSpace(customPronouns)
+	if pronouns == "" && customPronouns != "" {
+		pronouns = pronounsCustom
+	}
+	switch _, known := findPronounOption(pronouns); {
+	case pronouns == pronounsCustom:
+		if _, err := parseCustomPronouns(customPronouns); err != nil {
+			fieldErrors["custom_pronouns"] = tr.DialogCustomPronounsInvalid
+		}
+	case pronouns != "" && !known:
+		fieldErrors["pronouns"] = tr.DialogCustomPronounsInvalid
+	default:
+		customPronouns = ""
+	}
+	return pronouns, customPronouns
+}
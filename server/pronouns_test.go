@@ -0,0 +1,88 @@
+package main
+
+import "testing"
+
+func TestFormatPronouns(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  SignatureData
+		long  string
+		short string
+	}{
+		{name: "none chosen", data: SignatureData{}},
+		{name: "pair", data: SignatureData{Pronouns: "sie"}, long: "Pronomen sie/ihr - pronouns she/her", short: "sie/ihr · she/her"},
+		{name: "mixed pair", data: SignatureData{Pronouns: "er-dey"}, long: "Pronomen er/dey - pronouns he/they", short: "er/dey · he/they"},
+		{name: "fixed phrase", data: SignatureData{Pronouns: "keine"}, long: "Keine Pronomen / No Pronouns", short: "Keine Pronomen · No Pronouns"},
+		{name: "custom pair", data: SignatureData{Pronouns: pronounsCustom, CustomPronouns: "xier/xiem / xe/xem"}, long: "Pronomen xier/xiem - pronouns xe/xem", short: "xier/xiem · xe/xem"},
+		{name: "custom for both languages", data: SignatureData{Pronouns: pronounsCustom, CustomPronouns: "xe/xem"}, long: "Pronomen/pronouns xe/xem", short: "xe/xem"},
+		{name: "typed fixed phrase", data: SignatureData{Pronouns: pronounsCustom, CustomPronouns: "alle pronomen / any pronouns"}, long: "Alle Pronomen / Any Pronouns", short: "Alle Pronomen · Any Pronouns"},
+		{name: "invalid custom", data: SignatureData{Pronouns: pronounsCustom, CustomPronouns: "<b>xe</b>"}},
+		// Free text stored before the select existed counts as custom
+		{name: "legacy free text", data: SignatureData{Pronouns: "they/them"}, long: "Pronomen/pronouns they/them", short: "they/them"},
+	}
+	for _, test := range tests {
+		if got := formatPronouns(test.data); got != test.long {
+			t.Errorf("%s: formatPronouns = %q, want %q", test.name, got, test.long)
+		}
+		if got := shortPronouns(test.data); got != test.short {
+			t.Errorf("%s: shortPronouns = %q, want %q", test.name, got, test.short)
+		}
+	}
+}
+
+func TestParseCustomPronouns(t *testing.T) {
+	tests := []struct {
+		text    string
+		german  string
+		english string
+		valid   bool
+	}{
+		{text: "xier/xiem / xe/xem", german: "xier/xiem", english: "xe/xem", valid: true},
+		{text: "  xe/xem  ", german: "xe/xem", english: "xe/xem", valid: true},
+		{text: ""},
+		{text: "a / b / c"},
+		{text: "xe\nxem"},
+		{text: "xe/xem/xyr/xemself/xyrs/and/more/words/that/are/far/too/long/for/a/signature"},
+	}
+	for _, test := range tests {
+		option, err := parseCustomPronouns(test.text)
+		if (err == nil) != test.valid {
+			t.Errorf("parseCustomPronouns(%q): got error %v, want valid %t", test.text, err, test.valid)
+			continue
+		}
+		if test.valid && (option.German != test.german || option.English != test.english) {
+			t.Errorf("parseCustomPronouns(%q) = %q / %q, want %q / %q", test.text, option.German, option.English, test.german, test.english)
+		}
+	}
+}
+
+func TestReadPronounsSubmission(t *testing.T) {
+	tr := Translations{DialogCustomPronounsInvalid: "invalid"}
+	tests := []struct {
+		name       string
+		submission map[string]interface{}
+		pronouns   string
+		custom     string
+		errorField string
+	}{
+		{name: "empty", submission: map[string]interface{}{}},
+		{name: "option", submission: map[string]interface{}{"pronouns": "dey", "custom_pronouns": "ignored"}, pronouns: "dey"},
+		{name: "custom", submission: map[string]interface{}{"pronouns": pronounsCustom, "custom_pronouns": " xe/xem "}, pronouns: pronounsCustom, custom: "xe/xem"},
+		{name: "typed without choosing", submission: map[string]interface{}{"custom_pronouns": "xe/xem"}, pronouns: pronounsCustom, custom: "xe/xem"},
+		{name: "unknown option", submission: map[string]interface{}{"pronouns": "nope"}, pronouns: "nope", errorField: "pronouns"},
+		{name: "invalid custom", submission: map[string]interface{}{"pronouns": pronounsCustom}, pronouns: pronounsCustom, errorField: "custom_pronouns"},
+	}
+	for _, test := range tests {
+		fieldErrors := map[string]string{}
+		pronouns, custom := readPronounsSubmission(test.submission, tr, fieldErrors)
+		if pronouns != test.pronouns || custom != test.custom {
+			t.Errorf("%s: got %q, %q, want %q, %q", test.name, pronouns, custom, test.pronouns, test.custom)
+		}
+		if test.errorField == "" && len(fieldErrors) > 0 {
+			t.Errorf("%s: unexpected errors %v", test.name, fieldErrors)
+		}
+		if test.errorField != "" && fieldErrors[test.errorField] == "" {
+			t.Errorf("%s: expected an error on %s, got %v", test.name, test.errorField, fieldErrors)
+		}
+	}
+}
@@ -0,0 +1,784 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	quizAttemptsKVPrefix = "onboarding:quiz_attempts:"
+	// quizProgressKVPrefix holds the answers of a running attempt, keyed by
+	// user and step
+	quizProgressKVPrefix = "onboarding:quiz_progress:"
+	// quizProgressExpirySeconds is how long a started attempt can be finished
+	quizProgressExpirySeconds = 24 * 60 * 60
+)
+
+var (
+	errQuizAlreadyPassed   = errors.New("quiz already passed")
+	errQuizNoAttemptsLeft  = errors.New("no quiz attempts left")
+	errQuizAttemptNotFound = errors.New("quiz attempt not found")
+)
+
+// QuizDefinition is a multiple-choice quiz attached to a checklist step
+type QuizDefinition struct {
+	Step string `json:"step"`
+	// PassPercent is the share of correct answers needed to pass; 0 means all
+	PassPercent int `json:"pass_percent,omitempty"`
+	// MaxAttempts limits the attempts per user; 0 means no limit
+	MaxAttempts int            `json:"max_attempts,omitempty"`
+	Questions   []QuizQuestion `json:"questions"`
+}
+
+// QuizQuestion is one question with its options and the id of the right one
+type QuizQuestion struct {
+	ID      string        `json:"id"`
+	Text    LocalizedText `json:"text"`
+	Options []QuizOption  `json:"options"`
+	Answer  string        `json:"answer"`
+}
+
+// QuizOption is one answer to choose from
+type QuizOption struct {
+	ID   string        `json:"id"`
+	Text LocalizedText `json:"text"`
+}
+
+// QuizAttempt is one run through a quiz. Attempts are appended when the quiz
+// starts and filled in once the last question is answered; finished attempts
+// are never changed.
+type QuizAttempt struct {
+	ID          string       `json:"id,omitempty"`
+	Step        string       `json:"step"`
+	Answers     []QuizAnswer `json:"answers"`
+	Correct     int          `json:"correct"`
+	Total       int          `json:"total"`
+	Passed      bool         `json:"passed"`
+	StartedAt   time.Time    `json:"started_at,omitzero"`
+	SubmittedAt time.Time    `json:"submitted_at,omitzero"`
+}
+
+// finished reports whether the attempt was submitted
+func (a QuizAttempt) finished() bool {
+	return !a.SubmittedAt.IsZero()
+}
+
+// QuizAnswer records the option chosen for a question. Correct is stored so
+// later changes to the answer key don't rewrite old results.
+type QuizAnswer struct {
+	Question string `json:"question"`
+	Option   string `json:"option"`
+	Correct  bool   `json:"correct"`
+}
+
+// quizProgress is the running attempt, kept server-side between the question
+// dialogs so users can't edit their answers or score
+type quizProgress struct {
+	Step      string            `json:"step"`
+	AttemptID string            `json:"attempt_id"`
+	Answers   map[string]string `json:"answers"`
+}
+
+// parseQuizDefinitions parses and validates the Quizzes setting against the
+// configured steps
+func parseQuizDefinitions(raw string, steps []StepDefinition) ([]QuizDefinition, error) {
+	var quizzes []QuizDefinition
+	if err := json.Unmarshal([]byte(raw), &quizzes); err != nil {
+		return nil, fmt.Errorf("parse quizzes: %w", err)
+	}
+
+	seen := make(map[string]struct{}, len(quizzes))
+	for i, quiz := range quizzes {
+		if _, ok := findStep(steps, quiz.Step); !ok {
+			return nil, fmt.Errorf("quiz %d: unknown step %q", i+1, quiz.Step)
+		}
+		if _, ok := seen[quiz.Step]; ok {
+			return nil, fmt.Errorf("quiz %q: duplicate step", quiz.Step)
+		}
+		seen[quiz.Step] = struct{}{}
+
+		if quiz.PassPercent < 0 || quiz.PassPercent > 100 {
+			return nil, fmt.Errorf("quiz %q: pass_percent must be between 0 and 100", quiz.Step)
+		}
+		if quiz.MaxAttempts < 0 {
+			return nil, fmt.Errorf("quiz %q: max_attempts must not be negative", quiz.Step)
+		}
+		if len(quiz.Questions) == 0 {
+			return nil, fmt.Errorf("quiz %q: at least one question is required", quiz.Step)
+		}
+
+		questionIDs := make(map[string]struct{}, len(quiz.Questions))
+		for j, question := range quiz.Questions {
+			if strings.TrimSpace(question.ID) == "" {
+				return nil, fmt.Errorf("quiz %q: question %d: id is required", quiz.Step, j+1)
+			}
+			if _, ok := questionIDs[question.ID]; ok {
+				return nil, fmt.Errorf("quiz %q: duplicate question %q", quiz.Step, question.ID)
+			}
+			questionIDs[question.ID] = struct{}{}
+
+			if question.Text.Get("en") == "" {
+				return nil, fmt.Errorf("quiz %q: question %q: text is required", quiz.Step, question.ID)
+			}
+			if len(question.Options) < 2 {
+				return nil, fmt.Errorf("quiz %q: question %q: at least two options are required", quiz.Step, question.ID)
+			}
+			for _, option := range question.Options {
+				if strings.TrimSpace(option.ID) == "" || option.Text.Get("en") == "" {
+					return nil, fmt.Errorf("quiz %q: question %q: options need an id and a text", quiz.Step, question.ID)
+				}
+			}
+			if !question.hasOption(question.Answer) {
+				return nil, fmt.Errorf("quiz %q: question %q: answer %q is not an option", quiz.Step, question.ID, question.Answer)
+			}
+		}
+	}
+
+	return quizzes, nil
+}
+
+// getQuizzes returns the quizzes parsed in OnConfigurationChange. An empty
+// or invalid setting means no step has a quiz.
+func (p *Plugin) getQuizzes() []QuizDefinition {
+	p.settings.RLock()
+	defer p.settings.RUnlock()
+	return p.settings.quizzes
+}
+
+// findQuiz returns the quiz attached to a step
+func findQuiz(quizzes []QuizDefinition, stepID string) (QuizDefinition, bool) {
+	for _, quiz := range quizzes {
+		if quiz.Step == stepID {
+			return quiz, true
+		}
+	}
+	return QuizDefinition{}, false
+}
+
+// hasOption reports whether id is one of the question's options
+func (q QuizQuestion) hasOption(id string) bool {
+	for _, option := range q.Options {
+		if option.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredCorrect is the number of correct answers needed to pass
+func (q QuizDefinition) requiredCorrect() int {
+	total := len(q.Questions)
+	if q.PassPercent == 0 {
+		return total
+	}
+	// Round up, so 80% of 4 questions needs all 4 rather than 3
+	return (q.PassPercent*total + 99) / 100
+}
+
+// gradeQuiz scores the answers against the quiz. Unanswered questions count
+// as wrong.
+func gradeQuiz(quiz QuizDefinition, answers map[string]string) QuizAttempt {
+	attempt := QuizAttempt{
+		Step:    quiz.Step,
+		Answers: make([]QuizAnswer, 0, len(quiz.Questions)),
+		Total:   len(quiz.Questions),
+	}
+	for _, question := range quiz.Questions {
+		answer := QuizAnswer{Question: question.ID, Option: answers[question.ID]}
+		answer.Correct = answer.Option == question.Answer
+		if answer.Correct {
+			attempt.Correct++
+		}
+		attempt.Answers = append(attempt.Answers, answer)
+	}
+	attempt.Passed = attempt.Correct >= quiz.requiredCorrect()
+	return attempt
+}
+
+// quizResult summarizes a user's attempts at the quiz of a step. Started
+// attempts count as used even before they are submitted.
+func quizResult(attempts []QuizAttempt, stepID string) (passed *QuizAttempt, used int) {
+	for i := range attempts {
+		if attempts[i].Step != stepID {
+			continue
+		}
+		used++
+		if attempts[i].Passed && passed == nil {
+			passed = &attempts[i]
+		}
+	}
+	return passed, used
+}
+
+// hasAttemptsLeft reports whether the user may take the quiz again
+func (q QuizDefinition) hasAttemptsLeft(used int) bool {
+	return q.MaxAttempts == 0 || used < q.MaxAttempts
+}
+
+// loadQuizAttempts returns the user's quiz attempts, oldest first
+func (p *Plugin) loadQuizAttempts(userID string) ([]QuizAttempt, error) {
+	data, appErr := p.API.KVGet(quizAttemptsKVPrefix + userID)
+	if appErr != nil {
+		return nil, fmt.Errorf("KVGet: %w", appErr)
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var attempts []QuizAttempt
+	if err := json.Unmarshal(data, &attempts); err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// updateQuizAttempts applies update to the user's attempts with
+// compare-and-set, so concurrent dialogs don't overwrite each other
+func (p *Plugin) updateQuizAttempts(userID string, update func([]QuizAttempt) ([]QuizAttempt, error)) error {
+	key := quizAttemptsKVPrefix + userID
+	for try := 0; try < appendEventAttempts; try++ {
+		oldData, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return fmt.Errorf("KVGet: %w", appErr)
+		}
+
+		var attempts []QuizAttempt
+		if oldData != nil {
+			if err := json.Unmarshal(oldData, &attempts); err != nil {
+				return err
+			}
+		}
+		attempts, err := update(attempts)
+		if err != nil {
+			return err
+		}
+
+		newData, err := json.Marshal(attempts)
+		if err != nil {
+			return err
+		}
+
+		ok, appErr := p.API.KVCompareAndSet(key, oldData, newData)
+		if appErr != nil {
+			return fmt.Errorf("KVCompareAndSet: %w", appErr)
+		}
+		if ok {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("update quiz attempts: too many concurrent updates")
+}
+
+// reserveQuizAttempt records a new attempt when the quiz starts, so parallel
+// dialogs can't take more than max_attempts
+func (p *Plugin) reserveQuizAttempt(userID string, quiz QuizDefinition) (QuizAttempt, error) {
+	attempt := QuizAttempt{ID: model.NewId(), Step: quiz.Step, Total: len(quiz.Questions), StartedAt: time.Now().UTC()}
+	err := p.updateQuizAttempts(userID, func(attempts []QuizAttempt) ([]QuizAttempt, error) {
+		passed, used := quizResult(attempts, quiz.Step)
+		if passed != nil {
+			return nil, errQuizAlreadyPassed
+		}
+		if !quiz.hasAttemptsLeft(used) {
+			return nil, errQuizNoAttemptsLeft
+		}
+		return append(attempts, attempt), nil
+	})
+	return attempt, err
+}
+
+// releaseQuizAttempt removes a reserved attempt whose dialog couldn't be
+// opened
+func (p *Plugin) releaseQuizAttempt(userID, attemptID string) error {
+	return p.updateQuizAttempts(userID, func(attempts []QuizAttempt) ([]QuizAttempt, error) {
+		for i := range attempts {
+			if attempts[i].ID == attemptID && !attempts[i].finished() {
+				return append(attempts[:i], attempts[i+1:]...), nil
+			}
+		}
+		return nil, errQuizAttemptNotFound
+	})
+}
+
+// finishQuizAttempt stores the graded answers in the reserved attempt and
+// returns the attempts used at the quiz so far
+func (p *Plugin) finishQuizAttempt(userID string, graded QuizAttempt) (int, error) {
+	if graded.SubmittedAt.IsZero() {
+		graded.SubmittedAt = time.Now().UTC()
+	}
+
+	var used int
+	err := p.updateQuizAttempts(userID, func(attempts []QuizAttempt) ([]QuizAttempt, error) {
+		for i := range attempts {
+			if attempts[i].ID != graded.ID || attempts[i].finished() {
+				continue
+			}
+			graded.StartedAt = attempts[i].StartedAt
+			attempts[i] = graded
+			_, used = quizResult(attempts, graded.Step)
+			return attempts, nil
+		}
+		return nil, errQuizAttemptNotFound
+	})
+	return used, err
+}
+
+// loadQuizProgress returns the user's running attempt at the quiz of a step,
+// or nil if there is none, together with the stored value for
+// saveQuizProgress
+func (p *Plugin) loadQuizProgress(userID, stepID string) (*quizProgress, []byte, error) {
+	data, appErr := p.API.KVGet(quizProgressKVPrefix + userID + ":" + stepID)
+	if appErr != nil {
+		return nil, nil, fmt.Errorf("KVGet: %w", appErr)
+	}
+	if data == nil {
+		return nil, nil, nil
+	}
+
+	var progress quizProgress
+	if err := json.Unmarshal(data, &progress); err != nil {
+		return nil, nil, err
+	}
+	return &progress, data, nil
+}
+
+// saveQuizProgress stores the running attempt until it expires. With oldData
+// the write only succeeds if the stored progress is still oldData, so that
+// concurrent submissions of the same question can't both be recorded.
+func (p *Plugin) saveQuizProgress(userID string, progress *quizProgress, oldData []byte) (bool, error) {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return false, err
+	}
+	options := model.PluginKVSetOptions{ExpireInSeconds: quizProgressExpirySeconds}
+	if oldData != nil {
+		options.Atomic = true
+		options.OldValue = oldData
+	}
+	saved, appErr := p.API.KVSetWithOptions(quizProgressKVPrefix+userID+":"+progress.Step, data, options)
+	if appErr != nil {
+		return false, fmt.Errorf("KVSetWithOptions: %w", appErr)
+	}
+	return saved, nil
+}
+
+// quizDialogState identifies the question a quiz dialog shows, so that a
+// replayed submission can't be recorded as the answer to a later question
+func quizDialogState(attemptID, questionID string) string {
+	return attemptID + ":" + questionID
+}
+
+func (p *Plugin) deleteQuizProgress(userID, stepID string) error {
+	if appErr := p.API.KVDelete(quizProgressKVPrefix + userID + ":" + stepID); appErr != nil {
+		return fmt.Errorf("KVDelete: %w", appErr)
+	}
+	return nil
+}
+
+// hasPassedQuiz reports whether the user passed the quiz of a step
+func (p *Plugin) hasPassedQuiz(userID, stepID string) (bool, error) {
+	attempts, err := p.loadQuizAttempts(userID)
+	if err != nil {
+		return false, err
+	}
+	passed, _ := quizResult(attempts, stepID)
+	return passed != nil, nil
+}
+
+// renderQuizLine describes the quiz of a step and the user's progress, and
+// returns whether the user can start an attempt
+func (p *Plugin) renderQuizLine(state *OnboardingState, quiz QuizDefinition, tr Translations) (string, bool) {
+	attempts, err := p.loadQuizAttempts(state.UserID)
+	if err != nil {
+		p.API.LogWarn("failed to load quiz attempts", "user_id", state.UserID, "err", err.Error())
+		return "", false
+	}
+
+	passed, used := quizResult(attempts, quiz.Step)
+	if passed != nil {
+		return "\n\n" + fmt.Sprintf(tr.QuizPassedOn, passed.SubmittedAt.Format("2006-01-02"), passed.Correct, passed.Total), false
+	}
+
+	line := "\n\n" + fmt.Sprintf(tr.QuizLine, quiz.requiredCorrect(), len(quiz.Questions))
+	switch {
+	case !quiz.hasAttemptsLeft(used):
+		return line + " " + tr.QuizNoAttemptsLeft, false
+	case quiz.MaxAttempts > 0:
+		line += " " + fmt.Sprintf(tr.QuizAttemptsLeft, quiz.MaxAttempts-used)
+	}
+	return line, true
+}
+
+// handleStartQuiz opens the first question of a step's quiz from a checklist
+// button
+func (p *Plugin) handleStartQuiz(w http.ResponseWriter, req *model.PostActionIntegrationRequest, state *OnboardingState, stepID string) {
+	tr := p.translationsForUser(req.UserId)
+
+	if _, ok := findStep(p.stepsForState(state), stepID); !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	quiz, ok := findQuiz(p.getQuizzes(), stepID)
+	if !ok {
+		p.respondWithChecklist(w, req, state, tr.ErrorQuizUnavailable)
+		return
+	}
+
+	callbackURL, err := p.pluginURL()
+	if err != nil {
+		p.API.LogError("pluginURL not configured", "err", err.Error())
+		p.writeIntegrationResponse(w, &model.PostActionIntegrationResponse{EphemeralText: tr.ErrorGeneral})
+		return
+	}
+
+	// The attempt counts from here on, even if the dialog is abandoned
+	attempt, err := p.reserveQuizAttempt(req.UserId, quiz)
+	switch {
+	case errors.Is(err, errQuizAlreadyPassed):
+		p.respondWithChecklist(w, req, state, tr.QuizAlreadyPassed)
+		return
+	case errors.Is(err, errQuizNoAttemptsLeft):
+		p.respondWithChecklist(w, req, state, tr.QuizNoAttemptsLeft)
+		return
+	case err != nil:
+		p.API.LogError("failed to start quiz attempt", "user_id", req.UserId, "step", stepID, "err", err.Error())
+		p.writeIntegrationResponse(w, &model.PostActionIntegrationResponse{EphemeralText: tr.ErrorGeneral})
+		return
+	}
+
+	if err := p.openQuizDialog(req.UserId, req.TriggerId, callbackURL, quiz, attempt, p.languageForState(state)); err != nil {
+		p.API.LogError("failed to open quiz dialog", "user_id", req.UserId, "step", stepID, "err", err.Error())
+		if err := p.releaseQuizAttempt(req.UserId, attempt.ID); err != nil {
+			p.API.LogWarn("failed to release quiz attempt", "user_id", req.UserId, "step", stepID, "err", err.Error())
+		}
+		p.writeIntegrationResponse(w, &model.PostActionIntegrationResponse{EphemeralText: tr.ErrorGeneral})
+		return
+	}
+	p.writeIntegrationResponse(w, &model.PostActionIntegrationResponse{EphemeralText: tr.DialogOpening})
+}
+
+// openQuizDialog stores the progress of a reserved attempt and opens its
+// first question
+func (p *Plugin) openQuizDialog(userID, triggerID, callbackURL string, quiz QuizDefinition, attempt QuizAttempt, language string) error {
+	// A new attempt replaces whatever progress an abandoned one left behind
+	progress := &quizProgress{Step: quiz.Step, AttemptID: attempt.ID, Answers: map[string]string{}}
+	if _, err := p.saveQuizProgress(userID, progress, nil); err != nil {
+		return err
+	}
+
+	if appErr := p.API.OpenInteractiveDialog(model.OpenDialogRequest{
+		TriggerId: triggerID,
+		URL:       callbackURL + "/submit-quiz",
+		Dialog:    p.quizDialog(quiz, progress, language),
+	}); appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+// quizDialog builds the dialog of the next unanswered question. Its state is
+// only the attempt and question id; the answers stay in the KV store.
+func (p *Plugin) quizDialog(quiz QuizDefinition, progress *quizProgress, language string) model.Dialog {
+	tr := p.translationsFor(language)
+
+	index := len(progress.Answers)
+	question := quiz.Questions[index]
+
+	options := make([]*model.PostActionOptions, 0, len(question.Options))
+	for _, option := range question.Options {
+		options = append(options, &model.PostActionOptions{Text: option.Text.Get(language), Value: option.ID})
+	}
+
+	submitLabel := tr.DialogQuizNext
+	if index == len(quiz.Questions)-1 {
+		submitLabel = tr.DialogQuizFinish
+	}
+
+	return model.Dialog{
+		CallbackId:       quiz.Step,
+		Title:            fmt.Sprintf(tr.DialogQuizTitle, index+1, len(quiz.Questions)),
+		IntroductionText: question.Text.Get(language),
+		Elements: []model.DialogElement{{
+			DisplayName: tr.DialogQuizAnswer,
+			Name:        "answer",
+			Type:        "radio",
+			Options:     options,
+		}},
+		SubmitLabel:    submitLabel,
+		NotifyOnCancel: false,
+		State:          quizDialogState(progress.AttemptID, question.ID),
+	}
+}
+
+// handleQuizSubmission stores the answer to one question and shows the next
+// one. After the last question the attempt is graded and recorded.
+func (p *Plugin) handleQuizSubmission(w http.ResponseWriter, r *http.Request, userID string) {
+	var submission model.SubmitDialogRequest
+	if err := json.NewDecoder(r.Body).Decode(&submission); err != nil {
+		p.API.LogError("failed to decode dialog submission", "err", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !p.verifyActingUser(w, r, userID, submission.UserId) {
+		return
+	}
+
+	writeResponse := func(resp *model.SubmitDialogResponse) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			p.API.LogError("failed to encode dialog response", "err", err.Error())
+		}
+	}
+
+	state, err := p.loadState(userID)
+	if err != nil {
+		p.API.LogError("failed to load onboarding state", "user_id", userID, "err", err.Error())
+		writeResponse(&model.SubmitDialogResponse{Error: p.translationsForUser(userID).ErrorGeneral})
+		return
+	}
+	if state == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	language := p.languageForState(state)
+	tr := p.translationsFor(language)
+
+	stepDef, ok := findStep(p.stepsForState(state), submission.CallbackId)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	progress, oldData, err := p.loadQuizProgress(userID, stepDef.ID)
+	if err != nil {
+		p.API.LogError("failed to load quiz progress", "user_id", userID, "step", stepDef.ID, "err", err.Error())
+		writeResponse(&model.SubmitDialogResponse{Error: tr.ErrorGeneral})
+		return
+	}
+	// The attempt may have expired or been replaced by a newer dialog, the
+	// quiz may have been changed or removed while the dialog was open, and a
+	// replayed submission names a question that has already been answered
+	quiz, ok := findQuiz(p.getQuizzes(), stepDef.ID)
+	if progress == nil || !ok || len(progress.Answers) >= len(quiz.Questions) {
+		writeResponse(&model.SubmitDialogResponse{Error: tr.ErrorQuizUnavailable})
+		return
+	}
+	question := quiz.Questions[len(progress.Answers)]
+	if submission.State != quizDialogState(progress.AttemptID, question.ID) {
+		writeResponse(&model.SubmitDialogResponse{Error: tr.ErrorQuizUnavailable})
+		return
+	}
+
+	answer, _ := submission.Submission["answer"].(string)
+	if answer == "" {
+		writeResponse(&model.SubmitDialogResponse{Errors: map[string]string{"answer": tr.DialogFieldRequired}})
+		return
+	}
+	if !question.hasOption(answer) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if progress.Answers == nil {
+		progress.Answers = map[string]string{}
+	}
+	progress.Answers[question.ID] = answer
+
+	if len(progress.Answers) < len(quiz.Questions) {
+		saved, err := p.saveQuizProgress(userID, progress, oldData)
+		if err != nil {
+			p.API.LogError("failed to save quiz progress", "user_id", userID, "step", quiz.Step, "err", err.Error())
+			writeResponse(&model.SubmitDialogResponse{Error: tr.ErrorGeneral})
+			return
+		}
+		if !saved {
+			// Another submission of this question got there first
+			writeResponse(&model.SubmitDialogResponse{Error: tr.ErrorQuizUnavailable})
+			return
+		}
+		next := p.quizDialog(quiz, progress, language)
+		writeResponse(&model.SubmitDialogResponse{Type: string(model.SubmitDialogResponseTypeForm), Form: &next})
+		return
+	}
+
+	attempt := gradeQuiz(quiz, progress.Answers)
+	attempt.ID = progress.AttemptID
+	used, err := p.finishQuizAttempt(userID, attempt)
+	if errors.Is(err, errQuizAttemptNotFound) {
+		writeResponse(&model.SubmitDialogResponse{Error: tr.ErrorQuizUnavailable})
+		return
+	}
+	if err != nil {
+		p.API.LogError("failed to save quiz attempt", "user_id", userID, "step", quiz.Step, "err", err.Error())
+		writeResponse(&model.SubmitDialogResponse{Error: tr.ErrorGeneral})
+		return
+	}
+	if err := p.deleteQuizProgress(userID, quiz.Step); err != nil {
+		p.API.LogWarn("failed to delete quiz progress", "user_id", userID, "step", quiz.Step, "err", err.Error())
+	}
+
+	message := fmt.Sprintf(tr.QuizPassed, stepDef.Title.Get(language), attempt.Correct, attempt.Total)
+	if !attempt.Passed {
+		hint := tr.QuizRetryHint
+		if !quiz.hasAttemptsLeft(used) {
+			hint = tr.QuizNoAttemptsLeft
+		}
+		message = fmt.Sprintf(tr.QuizFailed, attempt.Correct, attempt.Total, quiz.requiredCorrect(), stepDef.Title.Get(language)) + " " + hint
+	}
+
+	// Passing completes the step once its other checks pass too
+	if user, appErr := p.API.GetUser(userID); appErr == nil {
+		p.verifyOpenSteps(user, state)
+		p.refreshChecklistPost(user, state)
+	}
+
+	p.API.SendEphemeralPost(userID, &model.Post{
+		UserId:    p.botUserID,
+		ChannelId: submission.ChannelId,
+		Message:   message,
+	})
+	writeResponse(&model.SubmitDialogResponse{})
+}
+
+// QuizStats are the results of a quiz across all users
+type QuizStats struct {
+	Step           string              `json:"step"`
+	Users          int                 `json:"users"`
+	PassedUsers    int                 `json:"passed_users"`
+	Attempts       int                 `json:"attempts"`
+	PassedAttempts int                 `json:"passed_attempts"`
+	Questions      []QuizQuestionStats `json:"questions"`
+}
+
+// QuizQuestionStats are the answers given to one question. Options counts
+// how often each option was chosen, to spot misleading ones.
+type QuizQuestionStats struct {
+	ID       string         `json:"id"`
+	Answered int            `json:"answered"`
+	Correct  int            `json:"correct"`
+	PassRate float64        `json:"pass_rate"`
+	Options  map[string]int `json:"options"`
+}
+
+// collectQuizStats aggregates the attempts of every user at a quiz. Questions
+// are listed in the quiz's current order; answers to removed questions are
+// left out.
+func (p *Plugin) collectQuizStats(quiz QuizDefinition) (*QuizStats, error) {
+	const perPage = 200
+
+	stats := &QuizStats{Step: quiz.Step, Questions: make([]QuizQuestionStats, 0, len(quiz.Questions))}
+	byQuestion := make(map[string]*QuizQuestionStats, len(quiz.Questions))
+	for _, question := range quiz.Questions {
+		stats.Questions = append(stats.Questions, QuizQuestionStats{ID: question.ID, Options: map[string]int{}})
+	}
+	for i := range stats.Questions {
+		byQuestion[stats.Questions[i].ID] = &stats.Questions[i]
+	}
+
+	for page := 0; ; page++ {
+		keys, appErr := p.API.KVList(page, perPage)
+		if appErr != nil {
+			return nil, fmt.Errorf("KVList: %w", appErr)
+		}
+		for _, key := range keys {
+			if !strings.HasPrefix(key, quizAttemptsKVPrefix) {
+				continue
+			}
+			attempts, err := p.loadQuizAttempts(strings.TrimPrefix(key, quizAttemptsKVPrefix))
+			if err != nil {
+				return nil, err
+			}
+
+			finished := 0
+			for _, attempt := range attempts {
+				if attempt.Step != quiz.Step || !attempt.finished() {
+					continue
+				}
+				finished++
+				stats.Attempts++
+				if attempt.Passed {
+					stats.PassedAttempts++
+				}
+				for _, answer := range attempt.Answers {
+					question, ok := byQuestion[answer.Question]
+					if !ok {
+						continue
+					}
+					question.Answered++
+					question.Options[answer.Option]++
+					if answer.Correct {
+						question.Correct++
+					}
+				}
+			}
+			if finished == 0 {
+				continue
+			}
+			stats.Users++
+			if passed, _ := quizResult(attempts, quiz.Step); passed != nil {
+				stats.PassedUsers++
+			}
+		}
+		if len(keys) < perPage {
+			break
+		}
+	}
+
+	for i := range stats.Questions {
+		if stats.Questions[i].Answered > 0 {
+			stats.Questions[i].PassRate = float64(stats.Questions[i].Correct) / float64(stats.Questions[i].Answered)
+		}
+	}
+	return stats, nil
+}
+
+// executeAdminQuiz handles /onboarding admin quiz <step>. Results span all
+// teams, so only system admins may see them.
+func (p *Plugin) executeAdminQuiz(args *model.CommandArgs, fields []string) (*model.CommandResponse, *model.AppError) {
+	tr := p.translationsForUser(args.UserId)
+
+	if !p.API.HasPermissionTo(args.UserId, model.PermissionManageSystem) {
+		return ephemeralResponse(tr.AdminPermissionDenied), nil
+	}
+	if len(fields) != 1 {
+		return ephemeralResponse(fmt.Sprintf(tr.AdminUsage, "/onboarding admin quiz <step>")), nil
+	}
+
+	quiz, ok := findQuiz(p.getQuizzes(), fields[0])
+	if !ok {
+		return ephemeralResponse(fmt.Sprintf(tr.AdminQuizUnknown, fields[0])), nil
+	}
+
+	stats, err := p.collectQuizStats(quiz)
+	if err != nil {
+		p.API.LogError("failed to collect quiz stats", "step", quiz.Step, "err", err.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+	if stats.Attempts == 0 {
+		return ephemeralResponse(fmt.Sprintf(tr.AdminQuizNoAttempts, quiz.Step)), nil
+	}
+
+	language := p.languageForUser(args.UserId)
+	lines := make([]string, 0, len(stats.Questions))
+	for i, question := range stats.Questions {
+		text := strings.ReplaceAll(quiz.Questions[i].Text.Get(language), "|", "\\|")
+		// Options are listed in the quiz's order, the right one in bold
+		picks := make([]string, 0, len(quiz.Questions[i].Options))
+		for _, option := range quiz.Questions[i].Options {
+			pick := fmt.Sprintf("%s: %d", strings.ReplaceAll(option.Text.Get(language), "|", "\\|"), question.Options[option.ID])
+			if option.ID == quiz.Questions[i].Answer {
+				pick = "**" + pick + "**"
+			}
+			picks = append(picks, pick)
+		}
+		lines = append(lines, fmt.Sprintf("| %s | %d | %d | %.0f%% | %s |",
+			text, question.Answered, question.Correct, question.PassRate*100, strings.Join(picks, ", ")))
+	}
+
+	return ephemeralResponse(fmt.Sprintf(tr.AdminQuizStatsHeader, quiz.Step, stats.PassedUsers, stats.Users, stats.PassedAttempts, stats.Attempts) + "\n" +
+		tr.AdminQuizStatsTableHeader + "\n|---|---|---|---|---|\n" + strings.Join(lines, "\n")), nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+)
+
+// ReminderRecord tracks the nudges sent to a user. It is stored separately
+// from OnboardingState so sending a reminder doesn't count as progress.
+type ReminderRecord struct {
+	Count      int       `json:"count"`
+	LastSentAt time.Time `json:"last_sent_at"`
+	// EscalatedAt is when the manager and buddy were last told about a stall
+	EscalatedAt time.Time `json:"escalated_at,omitzero"`
+}
+
+const (
+	onboardingReminderKVPrefix = "onboarding:reminder:"
+	reminderJobKey             = "onboarding_reminders"
+)
+
+// startReminderJob schedules the reminder job. The cluster job runs on only
+// one server at a time, and its cadence is re-read from the settings each run.
+func (p *Plugin) startReminderJob() error {
+	job, err := cluster.Schedule(p.API, reminderJobKey, p.nextReminderWait, p.runReminderJob)
+	if err != nil {
+		return fmt.Errorf("schedule reminder job: %w", err)
+	}
+	p.reminderJob = job
+	return nil
+}
+
+func (p *Plugin) nextReminderWait(now time.Time, metadata cluster.JobMetadata) time.Duration {
+	minutes := p.getPluginIntSetting("ReminderCheckIntervalMinutes", 60)
+	if minutes < 1 {
+		minutes = 1
+	}
+	return cluster.MakeWaitForInterval(time.Duration(minutes)*time.Minute)(now, metadata)
+}
+
+// runReminderJob DMs users whose onboarding stalled and escalates to their
+// manager and buddy when the stall lasts longer.
+func (p *Plugin) runReminderJob() {
+	userIDs, err := p.listStateUserIDs()
+	if err != nil {
+		p.API.LogError("reminder job: failed to list onboarding states", "err", err.Error())
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, userID := range userIDs {
+		if err := p.processStalledUser(userID, now); err != nil {
+			p.API.LogWarn("reminder job: failed to process user", "user_id", userID, "err", err.Error())
+		}
+	}
+}
+
+func (p *Plugin) processStalledUser(userID string, now time.Time) error {
+	state, err := p.loadState(userID)
+	if err != nil || state == nil {
+		return err
+	}
+
+	// Complete verifiable steps first so reminders don't list them
+	p.verifyUserSteps(state)
+
+	remaining := p.remainingSteps(state)
+	if len(remaining) == 0 {
+		return nil
+	}
+
+	record, err := p.loadReminderRecord(userID)
+	if err != nil {
+		return err
+	}
+
+	reminded := false
+	if p.getPluginBoolSetting("ReminderEnabled", false) {
+		if reminded, err = p.remindUserIfStalled(state, record, remaining, now); err != nil {
+			return err
+		}
+	}
+
+	escalated, err := p.escalateIfStalled(state, record, now)
+	if err != nil {
+		return err
+	}
+
+	if !reminded && !escalated {
+		return nil
+	}
+	return p.saveReminderRecord(userID, record)
+}
+
+func (p *Plugin) remindUserIfStalled(state *OnboardingState, record *ReminderRecord, remaining []StepDefinition, now time.Time) (bool, error) {
+	if record.Count >= p.getPluginIntSetting("ReminderMaxCount", 3) {
+		return false, nil
+	}
+
+	threshold := time.Duration(p.getPluginIntSetting("ReminderThresholdHours", 72)) * time.Hour
+	lastActivity := lastProgressAt(state)
+	if record.LastSentAt.After(lastActivity) {
+		lastActivity = record.LastSentAt
+	}
+	if now.Sub(lastActivity) < threshold {
+		return false, nil
+	}
+
+	user, appErr := p.API.GetUser(state.UserID)
+	if appErr != nil {
+		return false, appErr
+	}
+	if user.DeleteAt != 0 || user.IsBot {
+		return false, nil
+	}
+	if p.inQuietHours(now.In(user.GetTimezoneLocation())) {
+		return false, nil
+	}
+
+	if err := p.sendReminder(user, state, remaining); err != nil {
+		return false, err
+	}
+
+	record.Count++
+	record.LastSentAt = now
+	return true, nil
+}
+
+// remainingSteps returns the steps of the user's track that are still open
+func (p *Plugin) remainingSteps(state *OnboardingState) []StepDefinition {
+	var remaining []StepDefinition
+	for _, step := range p.stepsForState(state) {
+		if !state.CompletedSteps[step.ID] {
+			remaining = append(remaining, step)
+		}
+	}
+	return remaining
+}
+
+// lastProgressAt returns when the user last changed a step, or when
+// onboarding started. States saved before progress was tracked separately
+// fall back to their last update.
+func lastProgressAt(state *OnboardingState) time.Time {
+	if !state.LastProgressAt.IsZero() {
+		return state.LastProgressAt
+	}
+	if !state.LastUpdated.IsZero() {
+		return state.LastUpdated
+	}
+	return state.StartedAt
+}
+
+// inQuietHours reports whether the local time falls into the configured quiet
+// hours. Ranges may wrap around midnight (e.g. 20 to 8).
+func (p *Plugin) inQuietHours(local time.Time) bool {
+	start := p.getPluginIntSetting("ReminderQuietHoursStart", 20)
+	end := p.getPluginIntSetting("ReminderQuietHoursEnd", 8)
+	if start == end {
+		return false
+	}
+
+	hour := local.Hour()
+	if start < end {
+		return hour >= start && hour < end
+	}
+	return hour >= start || hour < end
+}
+
+func (p *Plugin) sendReminder(user *model.User, state *OnboardingState, remaining []StepDefinition) error {
+	language := p.resolveLanguage(user, state)
+	tr := p.translationsFor(language)
+
+	lines := make([]string, 0, len(remaining))
+	for _, step := range remaining {
+		lines = append(lines, "- "+checkbox(false)+step.Title.Get(language))
+	}
+
+	return p.sendBotDM(user.Id, fmt.Sprintf(tr.ReminderMessage.Select(len(remaining)), displayNameOf(user), len(remaining))+"\n\n"+
+		strings.Join(lines, "\n")+"\n\n"+
+		tr.ReminderClosing)
+}
+
+func (p *Plugin) loadReminderRecord(userID string) (*ReminderRecord, error) {
+	data, appErr := p.API.KVGet(onboardingReminderKVPrefix + userID)
+	if appErr != nil {
+		return nil, fmt.Errorf("KVGet: %w", appErr)
+	}
+
+	record := &ReminderRecord{}
+	if data == nil {
+		return record, nil
+	}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (p *Plugin) saveReminderRecord(userID string, record *ReminderRecord) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if appErr := p.API.KVSet(onboardingReminderKVPrefix+userID, b); appErr != nil {
+		return appErr
+	}
+	return nil
+}
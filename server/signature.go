@@ -2,8 +2,10 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/mattermost/mattermost/server/public/model"
@@ -11,26 +13,76 @@ import (
 
 // handleSignatureDialog opens an interactive dialog for EOTO signature generation
 func (p *Plugin) handleSignatureDialog(w http.ResponseWriter, r *http.Request, req *model.PostActionIntegrationRequest) {
-	// Get user info to pre-fill form
-	user, appErr := p.API.GetUser(req.UserId)
-	if appErr != nil {
-		p.API.LogError("failed to get user", "err", appErr.Error())
+	if err := p.openSignatureDialog(req.UserId, req.TriggerId, nil); err != nil {
+		p.API.LogError("failed to open dialog", "err", err.Error())
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
+	// Get translations
+	tr := p.translationsForUser(req.UserId)
+
+	// Return success response
+	resp := &model.PostActionIntegrationResponse{
+		EphemeralText: tr.DialogOpening,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// openSignatureDialog opens the signature generator dialog for a user. The
+// form is pre-filled from draft when the user edits a preview, else from the
+// user's last submission, else from their Mattermost profile.
+func (p *Plugin) openSignatureDialog(userID, triggerID string, draft *signatureDraft) error {
+	// Get user info to pre-fill form
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		return fmt.Errorf("get user: %w", appErr)
+	}
+
+	defaults := signatureDraft{
+		Data:     SignatureData{FullName: user.GetFullName(), Email: user.Email},
+		TextFile: true,
+	}
+	if draft != nil {
+		defaults = *draft
+	} else if profile, err := p.loadSignatureProfile(userID); err != nil {
+		p.API.LogWarn("failed to load signature profile", "user_id", userID, "err", err.Error())
+	} else if profile != nil {
+		// Start from the user's last submission
+		defaults = signatureDraft{Data: profile.Data, TextFile: profile.TextFile}
+	}
+
 	callbackURL, err := p.pluginURL()
 	if err != nil {
-		p.API.LogError("pluginURL error", "err", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return err
 	}
 
 	// Get translations
-	tr := p.getTranslations()
+	language := p.languageForUser(userID)
+	tr := p.translationsFor(language)
+
+	// Offer every template that isn't retired
+	templates, err := p.activeSignatureTemplates()
+	if err != nil {
+		return fmt.Errorf("list signature templates: %w", err)
+	}
+	if len(templates) == 0 {
+		return errors.New("no active signature templates")
+	}
+	projectOptions := make([]*model.PostActionOptions, 0, len(templates))
+	defaultProject := templates[0].ID
+	for _, tmpl := range templates {
+		projectOptions = append(projectOptions, &model.PostActionOptions{Text: tmpl.Name.Get(language), Value: tmpl.ID})
+		if tmpl.ID == defaults.Data.Project {
+			defaultProject = tmpl.ID
+		}
+	}
+
+	pronounElements := pronounDialogElements(tr, defaults.Data.Pronouns, defaults.Data.CustomPronouns)
 
 	dialog := model.OpenDialogRequest{
-		TriggerId: req.TriggerId,
+		TriggerId: triggerID,
 		URL:       callbackURL + "/submit-signature",
 		Dialog: model.Dialog{
 			Title:            tr.DialogSignatureTitle,
@@ -41,7 +93,7 @@ func (p *Plugin) handleSignatureDialog(w http.ResponseWriter, r *http.Request, r
 					Name:        "full_name",
 					Type:        "text",
 					Placeholder: "Max Mustermann",
-					Default:     user.GetFullName(),
+					Default:     defaults.Data.FullName,
 					HelpText:    tr.DialogFullNameHelp,
 				},
 				{
@@ -49,22 +101,17 @@ func (p *Plugin) handleSignatureDialog(w http.ResponseWriter, r *http.Request, r
 					Name:        "position",
 					Type:        "text",
 					Placeholder: "Projektkoordinator*in",
+					Default:     defaults.Data.Position,
 					HelpText:    tr.DialogPositionHelp,
 				},
-				{
-					DisplayName: tr.DialogPronouns,
-					Name:        "pronouns",
-					Type:        "text",
-					Placeholder: tr.DialogPronounsPlaceholder,
-					Optional:    true,
-					HelpText:    tr.DialogPronounsHelp,
-				},
+				pronounElements[0],
+				pronounElements[1],
 				{
 					DisplayName: tr.DialogEmail,
 					Name:        "email",
 					Type:        "text",
 					SubType:     "email",
-					Default:     user.Email,
+					Default:     defaults.Data.Email,
 					HelpText:    tr.DialogEmailHelp,
 				},
 				{
@@ -72,15 +119,8 @@ func (p *Plugin) handleSignatureDialog(w http.ResponseWriter, r *http.Request, r
 					Name:        "project",
 					Type:        "select",
 					HelpText:    tr.DialogProjectHelp,
-					Options: []*model.PostActionOptions{
-						{Text: tr.ProjectEachOne, Value: "each-one"},
-						{Text: tr.ProjectCommunity, Value: "community"},
-						{Text: tr.ProjectCUZ, Value: "cuz"},
-						{Text: tr.ProjectJugend, Value: "jugend"},
-						{Text: tr.ProjectNAR, Value: "nar"},
-						{Text: tr.ProjectAfrolution, Value: "afrolution"},
-					},
-					Default: "each-one",
+					Options:     projectOptions,
+					Default:     defaultProject,
 				},
 				{
 					DisplayName: tr.DialogWorkNumber,
@@ -89,8 +129,28 @@ func (p *Plugin) handleSignatureDialog(w http.ResponseWriter, r *http.Request, r
 					SubType:     "tel",
 					Optional:    true,
 					Placeholder: tr.DialogWorkNumberPlaceholder,
+					Default:     defaults.Data.WorkNumber,
 					HelpText:    tr.DialogWorkNumberHelp,
 				},
+				{
+					DisplayName: tr.DialogMobileNumber,
+					Name:        "mobile_number",
+					Type:        "text",
+					SubType:     "tel",
+					Optional:    true,
+					Placeholder: tr.DialogMobileNumberPlaceholder,
+					Default:     defaults.Data.MobileNumber,
+					HelpText:    tr.DialogMobileNumberHelp,
+				},
+				{
+					DisplayName: tr.DialogTextFile,
+					Name:        "text_file",
+					Type:        "bool",
+					Placeholder: tr.DialogTextFileOption,
+					Default:     strconv.FormatBool(defaults.TextFile),
+					Optional:    true,
+					HelpText:    tr.DialogTextFileHelp,
+				},
 			},
 			SubmitLabel:    tr.DialogSubmitButton,
 			NotifyOnCancel: false,
@@ -98,57 +158,64 @@ func (p *Plugin) handleSignatureDialog(w http.ResponseWriter, r *http.Request, r
 	}
 
 	if appErr := p.API.OpenInteractiveDialog(dialog); appErr != nil {
-		p.API.LogError("failed to open dialog", "err", appErr.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return fmt.Errorf("open dialog: %w", appErr)
 	}
 
-	// Return success response
-	resp := &model.PostActionIntegrationResponse{
-		EphemeralText: tr.DialogOpening,
-	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	return nil
 }
 
 // handleSignatureSubmission processes the dialog submission and generates the EOTO signature
-func (p *Plugin) handleSignatureSubmission(w http.ResponseWriter, r *http.Request) {
+func (p *Plugin) handleSignatureSubmission(w http.ResponseWriter, r *http.Request, userID string) {
 	var submission model.SubmitDialogRequest
 	if err := json.NewDecoder(r.Body).Decode(&submission); err != nil {
 		p.API.LogError("failed to decode dialog submission", "err", err.Error())
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-
-	userID := submission.UserId
-	channelID := submission.ChannelId
+	if !p.verifyActingUser(w, r, userID, submission.UserId) {
+		return
+	}
 
 	// Extract form data
 	fullName, _ := submission.Submission["full_name"].(string)
 	position, _ := submission.Submission["position"].(string)
-	pronouns, _ := submission.Submission["pronouns"].(string)
 	email, _ := submission.Submission["email"].(string)
 	project, _ := submission.Submission["project"].(string)
 	workNumber, _ := submission.Submission["work_number"].(string)
+	mobileNumber, _ := submission.Submission["mobile_number"].(string)
+	textFile, _ := submission.Submission["text_file"].(bool)
+
+	tr := p.translationsForUser(userID)
 
 	// Validate required fields
-	errors := make(map[string]string)
-	if fullName == "" {
-		errors["full_name"] = "Full name is required"
-	}
-	if position == "" {
-		errors["position"] = "Position is required"
-	}
-	if email == "" {
-		errors["email"] = "Email is required"
+	fieldErrors := make(map[string]string)
+	for field, value := range map[string]string{"full_name": fullName, "position": position, "email": email, "project": project} {
+		if value == "" {
+			fieldErrors[field] = tr.DialogFieldRequired
+		}
 	}
-	if project == "" {
-		errors["project"] = "Project is required"
+	pronouns, customPronouns := readPronounsSubmission(submission.Submission, tr, fieldErrors)
+
+	// Phone numbers are stored in house style
+	for field, number := range map[string]*string{"work_number": &workNumber, "mobile_number": &mobileNumber} {
+		if strings.TrimSpace(*number) == "" {
+			*number = ""
+			continue
+		}
+		parsed, err := parsePhoneNumber(*number)
+		switch {
+		case errors.Is(err, errPhoneNumberLength):
+			fieldErrors[field] = tr.DialogPhoneNumberLength
+		case err != nil:
+			fieldErrors[field] = tr.DialogPhoneNumberInvalid
+		default:
+			*number = parsed.String()
+		}
 	}
 
-	if len(errors) > 0 {
+	if len(fieldErrors) > 0 {
 		resp := &model.SubmitDialogResponse{
-			Errors: errors,
+			Errors: fieldErrors,
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(resp)
@@ -157,80 +224,151 @@ func (p *Plugin) handleSignatureSubmission(w http.ResponseWriter, r *http.Reques
 
 	// Generate signature using EOTO templates
 	signatureData := SignatureData{
-		FullName:   fullName,
-		Position:   position,
-		Pronouns:   pronouns,
-		Email:      email,
-		Project:    project,
-		WorkNumber: workNumber,
+		FullName:       fullName,
+		Position:       position,
+		Pronouns:       pronouns,
+		CustomPronouns: customPronouns,
+		Email:          email,
+		Project:        project,
+		WorkNumber:     workNumber,
+		MobileNumber:   mobileNumber,
 	}
 
-	signatureHTML, err := GenerateSignature(signatureData)
+	// Render once so a retired template or broken HTML shows up in the dialog
+	_, err := p.generateSignature(signatureData)
+	if errors.Is(err, errTemplateNotFound) {
+		// The template was retired while the dialog was open
+		resp := &model.SubmitDialogResponse{
+			Errors: map[string]string{"project": tr.DialogProjectUnavailable},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
 	if err != nil {
 		p.API.LogError("failed to generate signature", "err", err.Error())
 
 		resp := &model.SubmitDialogResponse{
-			Error: "Failed to generate signature. Please try again.",
+			Error: tr.ErrorSignatureGeneration,
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(resp)
 		return
 	}
 
-	// Upload HTML file to Mattermost
-	fileID, err := p.uploadSignatureFile(userID, channelID, signatureHTML, fullName, project)
-	if err != nil {
-		p.API.LogError("failed to upload signature file", "err", err.Error())
+	// Show a preview first; the files are generated once the user confirms
+	draft := &signatureDraft{Data: signatureData, TextFile: textFile}
+	if err := p.rememberSignatureSubmission(userID, draft); err != nil {
+		p.API.LogWarn("failed to save signature profile", "user_id", userID, "err", err.Error())
+	}
+	if err := p.postSignaturePreview(userID, draft); err != nil {
+		p.API.LogError("failed to post signature preview", "user_id", userID, "err", err.Error())
 
 		resp := &model.SubmitDialogResponse{
-			Error: "Failed to upload signature file. Please try again.",
+			Error: tr.ErrorSignatureGeneration,
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(resp)
 		return
 	}
 
-	// Get DM channel with bot
+	// Return success (dialog will close)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&model.SubmitDialogResponse{})
+}
+
+// sendSignatureFiles renders the signature with the current template and
+// uploads the HTML file, and the plain-text version next to it, to the user's
+// bot DM with installation instructions. intro, if set, starts the message.
+func (p *Plugin) sendSignatureFiles(userID string, data SignatureData, textFile bool, intro string) error {
+	current, err := p.currentSignatureTemplate(data.Project)
+	if err != nil {
+		return err
+	}
+	signatureHTML, err := GenerateSignature(current.HTML, data)
+	if err != nil {
+		return err
+	}
+
+	// The signature always goes to the bot DM; the submitted channel id
+	// comes from the client and isn't trusted
 	dmChannel, appErr := p.API.GetDirectChannel(p.botUserID, userID)
 	if appErr != nil {
-		p.API.LogError("failed to get DM channel", "err", appErr.Error())
-		dmChannel = &model.Channel{Id: channelID} // Fallback to current channel
+		return fmt.Errorf("get DM channel: %w", appErr)
 	}
 
 	// Get translations
-	tr := p.getTranslations()
+	language := p.languageForUser(userID)
+	tr := p.translationsFor(language)
+	projectName := p.projectName(data.Project, language)
+
+	files := map[string]string{"html": signatureHTML}
+	if textFile {
+		if files["txt"], err = GenerateTextSignature(data, projectName, current); err != nil {
+			return err
+		}
+	}
+	var fileIDs []string
+	for _, extension := range []string{"html", "txt"} {
+		content, ok := files[extension]
+		if !ok {
+			continue
+		}
+		fileID, err := p.uploadSignatureFile(dmChannel.Id, content, signatureFilename(data.FullName, data.Project, extension))
+		if err != nil {
+			return fmt.Errorf("upload %s file: %w", extension, err)
+		}
+		fileIDs = append(fileIDs, fileID)
+	}
+
+	plainTextInstructions := ""
+	if textFile {
+		plainTextInstructions = tr.SignatureInstructionsPlainText
+	}
+	if intro != "" {
+		intro += "\n\n"
+	}
 
 	// Post message with download link
 	post := &model.Post{
 		UserId:    p.botUserID,
 		ChannelId: dmChannel.Id,
-		Message: tr.SignatureGeneratedTitle + "\n\n" +
-			fmt.Sprintf(tr.SignatureGeneratedMessage, fullName, formatProjectName(project, &tr)) +
+		Message: intro + tr.SignatureGeneratedTitle + "\n\n" +
+			fmt.Sprintf(tr.SignatureGeneratedMessage, data.FullName, projectName) +
 			tr.SignatureInstructionsTitle +
 			tr.SignatureInstructionsOutlook +
 			tr.SignatureInstructionsThunderbird +
-			fmt.Sprintf("_Project: %s_", formatProjectName(project, &tr)),
-		FileIds: []string{fileID},
+			plainTextInstructions +
+			fmt.Sprintf("_Project: %s_", projectName),
+		FileIds: fileIDs,
 	}
 
 	if _, appErr := p.API.CreatePost(post); appErr != nil {
-		p.API.LogError("failed to create post", "err", appErr.Error())
+		return fmt.Errorf("create post: %w", appErr)
 	}
 
-	// Return success (dialog will close)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(&model.SubmitDialogResponse{})
+	if err := p.recordSignatureSent(userID, data, textFile, current.Version); err != nil {
+		p.API.LogWarn("failed to save signature profile", "user_id", userID, "err", err.Error())
+	}
+	if p.getPluginBoolSetting("SyncPronounsToProfile", false) {
+		if err := p.syncPronounsToProfile(userID, data); err != nil {
+			p.API.LogWarn("failed to sync pronouns to profile", "user_id", userID, "err", err.Error())
+		}
+	}
+	return nil
 }
 
-// uploadSignatureFile uploads the generated signature HTML to Mattermost
-func (p *Plugin) uploadSignatureFile(userID, channelID string, htmlContent, fullName, project string) (string, error) {
-	// Create filename matching Python app format: {name}_{project}_Signatur.html
+// signatureFilename matches the Python app format: {name}_{project}_Signatur.{extension}.
+// The HTML and text files only differ in their extension.
+func signatureFilename(fullName, project, extension string) string {
 	sanitizedName := strings.ReplaceAll(fullName, " ", "_")
-	filename := fmt.Sprintf("%s_%s_Signatur.html", sanitizedName, project)
+	return fmt.Sprintf("%s_%s_Signatur.%s", sanitizedName, project, extension)
+}
 
-	// Upload file
+// uploadSignatureFile uploads a generated signature file to Mattermost
+func (p *Plugin) uploadSignatureFile(channelID, content, filename string) (string, error) {
 	fileInfo, appErr := p.API.UploadFile(
-		[]byte(htmlContent),
+		[]byte(content),
 		channelID,
 		filename,
 	)
@@ -240,23 +378,3 @@ func (p *Plugin) uploadSignatureFile(userID, channelID string, htmlContent, full
 
 	return fileInfo.Id, nil
 }
-
-// formatProjectName makes the project name more readable using translations
-func formatProjectName(project string, tr *Translations) string {
-	switch project {
-	case "each-one":
-		return tr.ProjectEachOne
-	case "community":
-		return tr.ProjectCommunity
-	case "cuz":
-		return tr.ProjectCUZ
-	case "jugend":
-		return tr.ProjectJugend
-	case "nar":
-		return tr.ProjectNAR
-	case "afrolution":
-		return tr.ProjectAfrolution
-	default:
-		return tr.ProjectEachOne
-	}
-}
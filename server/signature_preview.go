@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const signatureDraftKVPrefix = "onboarding:signature_draft:"
+
+// markdownEscaper escapes the characters Mattermost would format in preview lines
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "~", `\~`,
+	"[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`,
+)
+
+// signatureDraft is a submitted signature dialog waiting for the user to
+// confirm its preview
+type signatureDraft struct {
+	Data     SignatureData `json:"data"`
+	TextFile bool          `json:"text_file"`
+	// PreviewPostID is the preview whose buttons act on this draft
+	PreviewPostID string    `json:"preview_post_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// loadSignatureDraft returns the user's draft, or nil if there is none
+func (p *Plugin) loadSignatureDraft(userID string) (*signatureDraft, error) {
+	data, appErr := p.API.KVGet(signatureDraftKVPrefix + userID)
+	if appErr != nil {
+		return nil, fmt.Errorf("KVGet: %w", appErr)
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var draft signatureDraft
+	if err := json.Unmarshal(data, &draft); err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// saveSignatureDraft stores the user's draft until its preview buttons expire
+func (p *Plugin) saveSignatureDraft(userID string, draft *signatureDraft) error {
+	data, err := json.Marshal(draft)
+	if err != nil {
+		return err
+	}
+	options := model.PluginKVSetOptions{ExpireInSeconds: int64(p.actionSignatureTTL().Seconds())}
+	if _, appErr := p.API.KVSetWithOptions(signatureDraftKVPrefix+userID, data, options); appErr != nil {
+		return fmt.Errorf("KVSetWithOptions: %w", appErr)
+	}
+	return nil
+}
+
+// claimSignatureDraft takes the draft of a preview out of the KV store before
+// its files are sent, so a second click or a concurrent request finds nothing
+// to send. It returns nil if the draft is gone or belongs to another preview.
+func (p *Plugin) claimSignatureDraft(userID, previewPostID string) (*signatureDraft, error) {
+	data, appErr := p.API.KVGet(signatureDraftKVPrefix + userID)
+	if appErr != nil {
+		return nil, fmt.Errorf("KVGet: %w", appErr)
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var draft signatureDraft
+	if err := json.Unmarshal(data, &draft); err != nil {
+		return nil, err
+	}
+	if draft.PreviewPostID != previewPostID {
+		return nil, nil
+	}
+	deleted, appErr := p.API.KVCompareAndDelete(signatureDraftKVPrefix+userID, data)
+	if appErr != nil {
+		return nil, fmt.Errorf("KVCompareAndDelete: %w", appErr)
+	}
+	if !deleted {
+		return nil, nil
+	}
+	return &draft, nil
+}
+
+// signaturePreviewMessage approximates the signature in Markdown: the plain-text
+// lines as a quote, with the name in bold
+func (p *Plugin) signaturePreviewMessage(draft *signatureDraft, language string) (string, error) {
+	tr := p.translationsFor(language)
+	projectName := p.projectName(draft.Data.Project, language)
+
+	version, err := p.currentSignatureTemplate(draft.Data.Project)
+	if err != nil {
+		return "", err
+	}
+	text, err := GenerateTextSignature(draft.Data, projectName, version)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	quoted := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = markdownEscaper.Replace(line)
+		if line != "" && line == markdownEscaper.Replace(draft.Data.FullName) {
+			line = "**" + line + "**"
+		}
+		quoted = append(quoted, strings.TrimRight("> "+line, " "))
+	}
+
+	return fmt.Sprintf(tr.SignaturePreviewTitle, projectName) + "\n\n" +
+		strings.Join(quoted, "\n") + "\n\n" +
+		tr.SignaturePreviewHint, nil
+}
+
+// buildSignaturePreviewAttachments returns the send and edit buttons, signed
+// for the preview post
+func (p *Plugin) buildSignaturePreviewAttachments(userID, postID string) []*model.SlackAttachment {
+	tr := p.translationsForUser(userID)
+
+	pluginURL, err := p.pluginURL()
+	if err != nil {
+		p.API.LogError("pluginURL not configured", "err", err.Error())
+		return []*model.SlackAttachment{}
+	}
+	callbackURL := pluginURL + "/signature-action"
+
+	button := func(name, style, action string) *model.PostAction {
+		return &model.PostAction{
+			Name:  name,
+			Type:  model.PostActionTypeButton,
+			Style: style,
+			Integration: &model.PostActionIntegration{
+				URL:     callbackURL,
+				Context: p.signActionContext(userID, postID, action, ""),
+			},
+		}
+	}
+
+	return []*model.SlackAttachment{{
+		Actions: []*model.PostAction{
+			button(tr.ButtonSignatureSend, "primary", actionSendSignature),
+			button(tr.ButtonSignatureEdit, "default", actionEditSignature),
+		},
+	}}
+}
+
+// postSignaturePreview posts the preview of a submitted signature to the
+// user's bot DM and stores the draft its buttons act on
+func (p *Plugin) postSignaturePreview(userID string, draft *signatureDraft) error {
+	message, err := p.signaturePreviewMessage(draft, p.languageForUser(userID))
+	if err != nil {
+		return err
+	}
+
+	channel, appErr := p.API.GetDirectChannel(p.botUserID, userID)
+	if appErr != nil {
+		return fmt.Errorf("get DM channel: %w", appErr)
+	}
+
+	// Only the newest preview keeps working buttons
+	if previous, err := p.loadSignatureDraft(userID); err != nil {
+		p.API.LogWarn("failed to load signature draft", "user_id", userID, "err", err.Error())
+	} else if previous != nil && previous.PreviewPostID != "" {
+		p.closeSignaturePreview(previous.PreviewPostID, p.translationsForUser(userID).SignaturePreviewReplaced)
+	}
+
+	created, appErr := p.API.CreatePost(&model.Post{
+		UserId:    p.botUserID,
+		ChannelId: channel.Id,
+		Message:   message,
+	})
+	if appErr != nil {
+		return fmt.Errorf("create post: %w", appErr)
+	}
+
+	// Button contexts are signed for the post id, which only exists now
+	created.AddProp("attachments", p.buildSignaturePreviewAttachments(userID, created.Id))
+	if _, appErr := p.API.UpdatePost(created); appErr != nil {
+		return fmt.Errorf("update post: %w", appErr)
+	}
+
+	draft.PreviewPostID = created.Id
+	draft.CreatedAt = time.Now().UTC()
+	return p.saveSignatureDraft(userID, draft)
+}
+
+// closeSignaturePreview replaces a preview's buttons with a note
+func (p *Plugin) closeSignaturePreview(postID, note string) {
+	post, appErr := p.API.GetPost(postID)
+	if appErr != nil {
+		p.API.LogWarn("failed to get signature preview", "post_id", postID, "err", appErr.Error())
+		return
+	}
+
+	post.AddProp("attachments", []*model.SlackAttachment{{Text: note}})
+	if _, appErr := p.API.UpdatePost(post); appErr != nil {
+		p.API.LogWarn("failed to update signature preview", "post_id", postID, "err", appErr.Error())
+	}
+}
+
+// handleSignatureAction handles the buttons of a signature preview
+func (p *Plugin) handleSignatureAction(w http.ResponseWriter, r *http.Request, userID string) {
+	var req model.PostActionIntegrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		p.API.LogError("failed to decode integration request", "err", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !p.verifyActingUser(w, r, userID, req.UserId) {
+		return
+	}
+
+	tr := p.translationsForUser(userID)
+
+	// Buttons only work on the preview of the user's current draft
+	draft, err := p.loadSignatureDraft(userID)
+	if err != nil {
+		p.API.LogError("failed to load signature draft", "user_id", userID, "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if draft == nil || draft.PreviewPostID != req.PostId {
+		p.writeIntegrationResponse(w, &model.PostActionIntegrationResponse{
+			EphemeralText: tr.ErrorSignaturePreviewOutdated,
+		})
+		return
+	}
+
+	action, _, err := p.verifyActionContext(userID, req.PostId, req.Context)
+	if errors.Is(err, errActionSignatureExpired) || errors.Is(err, errActionSignatureMissing) {
+		// The post is the user's current preview; re-signing its buttons is safe
+		if post, appErr := p.API.GetPost(req.PostId); appErr == nil {
+			post.AddProp("attachments", p.buildSignaturePreviewAttachments(userID, req.PostId))
+			if _, appErr := p.API.UpdatePost(post); appErr != nil {
+				p.API.LogWarn("failed to update signature preview", "post_id", req.PostId, "err", appErr.Error())
+			}
+		}
+		p.writeIntegrationResponse(w, &model.PostActionIntegrationResponse{EphemeralText: tr.ErrorActionExpired})
+		return
+	}
+	if err != nil {
+		p.API.LogWarn("rejected button click with invalid signature", "user_id", userID, "post_id", req.PostId)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	switch action {
+	case actionSendSignature:
+		// Only the request that takes the draft sends it; the others are refused
+		claimed, err := p.claimSignatureDraft(userID, req.PostId)
+		if err != nil {
+			p.API.LogError("failed to claim signature draft", "user_id", userID, "err", err.Error())
+			p.writeIntegrationResponse(w, &model.PostActionIntegrationResponse{EphemeralText: tr.ErrorGeneral})
+			return
+		}
+		if claimed == nil {
+			p.writeIntegrationResponse(w, &model.PostActionIntegrationResponse{EphemeralText: tr.SignaturePreviewAlreadySent})
+			return
+		}
+
+		err = p.sendSignatureFiles(userID, claimed.Data, claimed.TextFile, "")
+		if err != nil {
+			// Nothing was sent; put the draft back so the preview can be retried
+			if err := p.saveSignatureDraft(userID, claimed); err != nil {
+				p.API.LogWarn("failed to restore signature draft", "user_id", userID, "err", err.Error())
+			}
+		}
+		if errors.Is(err, errTemplateNotFound) {
+			// The template was retired after the preview; let the user pick another
+			p.writeIntegrationResponse(w, &model.PostActionIntegrationResponse{
+				EphemeralText: tr.ErrorSignatureProjectUnavailable,
+			})
+			return
+		}
+		if err != nil {
+			p.API.LogError("failed to send signature files", "user_id", userID, "err", err.Error())
+			p.writeIntegrationResponse(w, &model.PostActionIntegrationResponse{EphemeralText: tr.ErrorGeneral})
+			return
+		}
+
+		p.closeSignaturePreview(req.PostId, tr.SignaturePreviewSent)
+		p.writeIntegrationResponse(w, &model.PostActionIntegrationResponse{})
+	case actionEditSignature:
+		if err := p.openSignatureDialog(userID, req.TriggerId, draft); err != nil {
+			p.API.LogError("failed to open dialog", "user_id", userID, "err", err.Error())
+			p.writeIntegrationResponse(w, &model.PostActionIntegrationResponse{EphemeralText: tr.ErrorGeneral})
+			return
+		}
+		p.writeIntegrationResponse(w, &model.PostActionIntegrationResponse{EphemeralText: tr.DialogOpening})
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
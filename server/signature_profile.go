@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const signatureProfileKVPrefix = "onboarding:signature_profile:"
+
+// errSignatureProfileNotFound means the user never submitted the signature dialog
+var errSignatureProfileNotFound = errors.New("signature profile not found")
+
+// SignatureProfile is a user's last signature submission, which pre-fills
+// the dialog, and the data of the files last sent, which is rebuilt when its
+// template changes. A preview that is never sent only changes Data.
+type SignatureProfile struct {
+	UserID   string        `json:"user_id"`
+	Data     SignatureData `json:"data"`
+	TextFile bool          `json:"text_file"`
+	// SentData and SentTextFile are what the last files were built from
+	SentData     *SignatureData `json:"sent_data,omitempty"`
+	SentTextFile bool           `json:"sent_text_file,omitempty"`
+	// SentVersion is the template version of the last files sent to the
+	// user; zero until the user confirms a preview
+	SentVersion int       `json:"sent_version,omitempty"`
+	SentAt      time.Time `json:"sent_at,omitzero"`
+	// SyncedPosition is the Mattermost position last set by
+	// syncPronounsToProfile
+	SyncedPosition string    `json:"synced_position,omitempty"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// sent returns the data and text file choice of the files last sent, and
+// false if the user never confirmed a preview
+func (s *SignatureProfile) sent() (SignatureData, bool, bool) {
+	if s.SentData == nil {
+		return SignatureData{}, false, false
+	}
+	return *s.SentData, s.SentTextFile, true
+}
+
+// resendResult summarizes a bulk signature regeneration
+type resendResult struct {
+	Sent    int `json:"sent"`
+	Current int `json:"current"`
+	Failed  int `json:"failed"`
+}
+
+// loadSignatureProfile returns the user's profile, or nil if there is none
+func (p *Plugin) loadSignatureProfile(userID string) (*SignatureProfile, error) {
+	data, appErr := p.API.KVGet(signatureProfileKVPrefix + userID)
+	if appErr != nil {
+		return nil, fmt.Errorf("KVGet: %w", appErr)
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var profile SignatureProfile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func (p *Plugin) saveSignatureProfile(profile *SignatureProfile) error {
+	profile.UpdatedAt = time.Now().UTC()
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	if appErr := p.API.KVSet(signatureProfileKVPrefix+profile.UserID, data); appErr != nil {
+		return fmt.Errorf("KVSet: %w", appErr)
+	}
+	return nil
+}
+
+// rememberSignatureSubmission stores a dialog submission to pre-fill the
+// dialog with, keeping the record of the files sent last
+func (p *Plugin) rememberSignatureSubmission(userID string, draft *signatureDraft) error {
+	profile, err := p.loadSignatureProfile(userID)
+	if err != nil {
+		return err
+	}
+	if profile == nil {
+		profile = &SignatureProfile{UserID: userID}
+	}
+	profile.Data = draft.Data
+	profile.TextFile = draft.TextFile
+	return p.saveSignatureProfile(profile)
+}
+
+// recordSignatureSent notes which data and template version the user's files
+// were built with
+func (p *Plugin) recordSignatureSent(userID string, data SignatureData, textFile bool, version int) error {
+	profile, err := p.loadSignatureProfile(userID)
+	if err != nil {
+		return err
+	}
+	if profile == nil {
+		profile = &SignatureProfile{UserID: userID, Data: data, TextFile: textFile}
+	}
+	profile.SentData = &data
+	profile.SentTextFile = textFile
+	profile.SentVersion = version
+	profile.SentAt = time.Now().UTC()
+	return p.saveSignatureProfile(profile)
+}
+
+// listSignatureProfiles returns the profiles of every user who was sent a
+// signature for the project
+func (p *Plugin) listSignatureProfiles(project string) ([]*SignatureProfile, error) {
+	const perPage = 200
+
+	var profiles []*SignatureProfile
+	for page := 0; ; page++ {
+		keys, appErr := p.API.KVList(page, perPage)
+		if appErr != nil {
+			return nil, fmt.Errorf("KVList: %w", appErr)
+		}
+		for _, key := range keys {
+			if !strings.HasPrefix(key, signatureProfileKVPrefix) {
+				continue
+			}
+			profile, err := p.loadSignatureProfile(strings.TrimPrefix(key, signatureProfileKVPrefix))
+			if err != nil {
+				p.API.LogWarn("failed to load signature profile", "key", key, "err", err.Error())
+				continue
+			}
+			if profile == nil {
+				continue
+			}
+			if data, _, ok := profile.sent(); ok && data.Project == project {
+				profiles = append(profiles, profile)
+			}
+		}
+		if len(keys) < perPage {
+			break
+		}
+	}
+	return profiles, nil
+}
+
+// regenerateSignature rebuilds the signature the user was sent last with the
+// current template and sends the files. Unconfirmed previews are ignored.
+func (p *Plugin) regenerateSignature(userID string, intro string) error {
+	profile, err := p.loadSignatureProfile(userID)
+	if err != nil {
+		return err
+	}
+	if profile == nil {
+		return errSignatureProfileNotFound
+	}
+	data, textFile, ok := profile.sent()
+	if !ok {
+		return errSignatureProfileNotFound
+	}
+	return p.sendSignatureFiles(userID, data, textFile, intro)
+}
+
+// resendProjectSignatures regenerates the signature of everyone who was sent
+// one for the project with an older template version
+func (p *Plugin) resendProjectSignatures(project string, dryRun bool) (resendResult, error) {
+	var result resendResult
+
+	current, err := p.currentSignatureTemplate(project)
+	if err != nil {
+		return result, err
+	}
+
+	profiles, err := p.listSignatureProfiles(project)
+	if err != nil {
+		return result, err
+	}
+
+	for _, profile := range profiles {
+		if profile.SentVersion >= current.Version {
+			result.Current++
+			continue
+		}
+		if dryRun {
+			result.Sent++
+			continue
+		}
+
+		language := p.languageForUser(profile.UserID)
+		intro := fmt.Sprintf(p.translationsFor(language).SignatureTemplateUpdated, p.projectName(project, language))
+		data, textFile, _ := profile.sent()
+		if err := p.sendSignatureFiles(profile.UserID, data, textFile, intro); err != nil {
+			p.API.LogError("failed to resend signature", "user_id", profile.UserID, "template_id", project, "err", err.Error())
+			result.Failed++
+			continue
+		}
+		result.Sent++
+	}
+	return result, nil
+}
+
+// syncPronounsToProfile shows the signature's position and pronouns as the
+// user's Mattermost position, "Projektkoordinator*in (sie/ihr · she/her)".
+// A position the user set themselves is never overwritten.
+func (p *Plugin) syncPronounsToProfile(userID string, data SignatureData) error {
+	pronouns := shortPronouns(data)
+	if pronouns == "" {
+		return nil
+	}
+	position := fmt.Sprintf("%s (%s)", data.Position, pronouns)
+	if utf8.RuneCountInString(position) > model.UserPositionMaxRunes {
+		return nil
+	}
+
+	profile, err := p.loadSignatureProfile(userID)
+	if err != nil {
+		return err
+	}
+	if profile == nil {
+		profile = &SignatureProfile{UserID: userID, Data: data}
+	}
+
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		return fmt.Errorf("get user: %w", appErr)
+	}
+	if user.Position == position {
+		return nil
+	}
+	if user.Position != "" && user.Position != profile.SyncedPosition {
+		return nil
+	}
+
+	user.Position = position
+	if _, appErr := p.API.UpdateUser(user); appErr != nil {
+		return fmt.Errorf("update user: %w", appErr)
+	}
+	profile.SyncedPosition = position
+	return p.saveSignatureProfile(profile)
+}
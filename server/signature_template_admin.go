@@ -0,0 +1,530 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// maxTemplateSize limits uploaded signature template requests
+const maxTemplateSize = 1 << 20
+
+// saveTemplateRequest is the body of PUT /api/v1/signature-templates/{id}
+type saveTemplateRequest struct {
+	Name         LocalizedText `json:"name"`
+	HTML         string        `json:"html"`
+	Organization string        `json:"organization"`
+	Address      string        `json:"address"`
+}
+
+// rollbackTemplateRequest is the body of POST /api/v1/signature-templates/{id}/rollback
+type rollbackTemplateRequest struct {
+	Version int `json:"version"`
+}
+
+// templateErrorStatus maps template store errors to HTTP status codes
+func templateErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errTemplateNotFound), errors.Is(err, errTemplateVersionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errTemplateInvalid):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeTemplateError answers with the status of a template store error.
+// Storage failures are logged rather than shown to the client.
+func (p *Plugin) writeTemplateError(w http.ResponseWriter, id string, err error) {
+	status := templateErrorStatus(err)
+	if status == http.StatusInternalServerError {
+		p.API.LogError("failed to update signature template", "template_id", id, "err", err.Error())
+		http.Error(w, "failed to update signature template", status)
+		return
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func (p *Plugin) handleListTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := p.listSignatureTemplates()
+	if err != nil {
+		p.API.LogError("failed to list signature templates", "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if templates == nil {
+		templates = []*SignatureTemplate{}
+	}
+	p.writeJSON(w, http.StatusOK, templates)
+}
+
+func (p *Plugin) handleGetTemplate(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := p.loadSignatureTemplate(r.PathValue("id"))
+	if err != nil {
+		p.API.LogError("failed to load signature template", "template_id", r.PathValue("id"), "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if tmpl == nil {
+		http.Error(w, "template not found", http.StatusNotFound)
+		return
+	}
+	p.writeJSON(w, http.StatusOK, tmpl)
+}
+
+// handleSaveTemplate creates a template or adds a version to it
+func (p *Plugin) handleSaveTemplate(w http.ResponseWriter, r *http.Request) {
+	var req saveTemplateRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxTemplateSize)).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	version := SignatureTemplateVersion{HTML: req.HTML, Organization: req.Organization, Address: req.Address}
+	tmpl, err := p.saveSignatureTemplateVersion(r.PathValue("id"), req.Name, version, r.Header.Get(headerUserID))
+	if err != nil {
+		p.writeTemplateError(w, r.PathValue("id"), err)
+		return
+	}
+	p.writeJSON(w, http.StatusOK, tmpl)
+}
+
+// handlePreviewTemplate renders a template version with sample data, as
+// HTML or, with ?format=text, as plain text
+func (p *Plugin) handlePreviewTemplate(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := p.loadSignatureTemplate(r.PathValue("id"))
+	if err != nil {
+		p.API.LogError("failed to load signature template", "template_id", r.PathValue("id"), "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if tmpl == nil {
+		http.Error(w, "template not found", http.StatusNotFound)
+		return
+	}
+
+	version := tmpl.Current()
+	if raw := r.URL.Query().Get("version"); raw != "" {
+		number, err := strconv.Atoi(raw)
+		if err != nil {
+			http.Error(w, "invalid version", http.StatusBadRequest)
+			return
+		}
+		var ok bool
+		if version, ok = tmpl.FindVersion(number); !ok {
+			http.Error(w, "version not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	if r.URL.Query().Get("format") == "text" {
+		text, err := GenerateTextSignature(sampleSignatureData, tmpl.Name.Get(p.getLanguage()), version)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(text))
+		return
+	}
+
+	html, err := GenerateSignature(version.HTML, sampleSignatureData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(html))
+}
+
+func (p *Plugin) handleRetireTemplate(retired bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tmpl, err := p.setSignatureTemplateRetired(r.PathValue("id"), retired)
+		if err != nil {
+			p.writeTemplateError(w, r.PathValue("id"), err)
+			return
+		}
+		p.writeJSON(w, http.StatusOK, tmpl)
+	}
+}
+
+// handleResendTemplate regenerates and re-sends the signatures of everyone
+// on the project whose files use an older template version. With
+// ?dry_run=true it only counts them.
+func (p *Plugin) handleResendTemplate(w http.ResponseWriter, r *http.Request) {
+	result, err := p.resendProjectSignatures(r.PathValue("id"), r.URL.Query().Get("dry_run") == "true")
+	if err != nil {
+		p.writeTemplateError(w, r.PathValue("id"), err)
+		return
+	}
+	p.writeJSON(w, http.StatusOK, result)
+}
+
+func (p *Plugin) handleRollbackTemplate(w http.ResponseWriter, r *http.Request) {
+	var req rollbackTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tmpl, err := p.rollbackSignatureTemplate(r.PathValue("id"), req.Version, r.Header.Get(headerUserID))
+	if err != nil {
+		p.writeTemplateError(w, r.PathValue("id"), err)
+		return
+	}
+	p.writeJSON(w, http.StatusOK, tmpl)
+}
+
+// executeAdminTemplate handles /onboarding admin template <subcommand>.
+// Templates are shared by all teams, so only system admins may change them.
+func (p *Plugin) executeAdminTemplate(args *model.CommandArgs, fields []string) (*model.CommandResponse, *model.AppError) {
+	tr := p.translationsForUser(args.UserId)
+
+	if !p.API.HasPermissionTo(args.UserId, model.PermissionManageSystem) {
+		return ephemeralResponse(tr.AdminPermissionDenied), nil
+	}
+
+	usage := "/onboarding admin template list|preview|history|edit|retire|restore|rollback|resend"
+	if len(fields) == 0 {
+		return ephemeralResponse(fmt.Sprintf(tr.AdminUsage, usage)), nil
+	}
+
+	switch fields[0] {
+	case "list":
+		return p.executeTemplateList(args)
+	case "preview":
+		if len(fields) < 2 || len(fields) > 3 {
+			return ephemeralResponse(fmt.Sprintf(tr.AdminUsage, "/onboarding admin template preview <id> [version]")), nil
+		}
+		return p.executeTemplatePreview(args, fields[1], fields[2:])
+	case "history":
+		if len(fields) != 2 {
+			return ephemeralResponse(fmt.Sprintf(tr.AdminUsage, "/onboarding admin template history <id>")), nil
+		}
+		return p.executeTemplateHistory(args, fields[1])
+	case "edit":
+		if len(fields) != 2 {
+			return ephemeralResponse(fmt.Sprintf(tr.AdminUsage, "/onboarding admin template edit <id>")), nil
+		}
+		return p.executeTemplateEdit(args, fields[1])
+	case "retire", "restore":
+		if len(fields) != 2 {
+			return ephemeralResponse(fmt.Sprintf(tr.AdminUsage, "/onboarding admin template "+fields[0]+" <id>")), nil
+		}
+		retired := fields[0] == "retire"
+		if _, err := p.setSignatureTemplateRetired(fields[1], retired); err != nil {
+			return p.templateCommandError(args, fields[1], "", err), nil
+		}
+		if retired {
+			return ephemeralResponse(fmt.Sprintf(tr.AdminTemplateRetired, fields[1])), nil
+		}
+		return ephemeralResponse(fmt.Sprintf(tr.AdminTemplateRestored, fields[1])), nil
+	case "rollback":
+		if len(fields) != 3 {
+			return ephemeralResponse(fmt.Sprintf(tr.AdminUsage, "/onboarding admin template rollback <id> <version>")), nil
+		}
+		version, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return ephemeralResponse(fmt.Sprintf(tr.AdminTemplateVersionNotFound, fields[1], fields[2])), nil
+		}
+		tmpl, err := p.rollbackSignatureTemplate(fields[1], version, args.UserId)
+		if err != nil {
+			return p.templateCommandError(args, fields[1], fields[2], err), nil
+		}
+		return ephemeralResponse(fmt.Sprintf(tr.AdminTemplateRolledBack, tmpl.ID, tmpl.Current().Version, version)), nil
+	case "resend":
+		if len(fields) < 2 || len(fields) > 3 || (len(fields) == 3 && fields[2] != "--dry-run") {
+			return ephemeralResponse(fmt.Sprintf(tr.AdminUsage, "/onboarding admin template resend <id> [--dry-run]")), nil
+		}
+		return p.executeTemplateResend(args, fields[1], len(fields) == 3)
+	default:
+		return ephemeralResponse(fmt.Sprintf(tr.CommandUnknown, fields[0]) + "\n\n" + fmt.Sprintf(tr.AdminUsage, usage)), nil
+	}
+}
+
+// templateCommandError turns a template store error into a command reply
+func (p *Plugin) templateCommandError(args *model.CommandArgs, id, version string, err error) *model.CommandResponse {
+	tr := p.translationsForUser(args.UserId)
+	switch {
+	case errors.Is(err, errTemplateNotFound):
+		return ephemeralResponse(fmt.Sprintf(tr.AdminTemplateNotFound, id))
+	case errors.Is(err, errTemplateVersionNotFound):
+		return ephemeralResponse(fmt.Sprintf(tr.AdminTemplateVersionNotFound, id, version))
+	case errors.Is(err, errTemplateInvalid):
+		return ephemeralResponse(fmt.Sprintf(tr.AdminTemplateInvalid, err.Error()))
+	default:
+		p.API.LogError("failed to update signature template", "template_id", id, "err", err.Error())
+		return ephemeralResponse(tr.ErrorGeneral)
+	}
+}
+
+func (p *Plugin) executeTemplateList(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+	language := p.languageForUser(args.UserId)
+	tr := p.translationsFor(language)
+
+	templates, err := p.listSignatureTemplates()
+	if err != nil {
+		p.API.LogError("failed to list signature templates", "err", err.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+	if len(templates) == 0 {
+		return ephemeralResponse(tr.AdminTemplateListEmpty), nil
+	}
+
+	lines := []string{tr.AdminTemplateListHeader}
+	for _, tmpl := range templates {
+		retired := ""
+		if tmpl.Retired {
+			retired = tr.AdminTemplateRetiredMarker
+		}
+		lines = append(lines, fmt.Sprintf(tr.AdminTemplateListEntry, tmpl.ID, tmpl.Name.Get(language), tmpl.Current().Version, retired))
+	}
+	return ephemeralResponse(strings.Join(lines, "\n")), nil
+}
+
+func (p *Plugin) executeTemplateHistory(args *model.CommandArgs, id string) (*model.CommandResponse, *model.AppError) {
+	tr := p.translationsForUser(args.UserId)
+
+	tmpl, err := p.loadSignatureTemplate(id)
+	if err != nil {
+		p.API.LogError("failed to load signature template", "template_id", id, "err", err.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+	if tmpl == nil {
+		return ephemeralResponse(fmt.Sprintf(tr.AdminTemplateNotFound, id)), nil
+	}
+
+	lines := []string{fmt.Sprintf(tr.AdminTemplateHistoryHeader, tmpl.ID)}
+	for i := len(tmpl.Versions) - 1; i >= 0; i-- {
+		version := tmpl.Versions[i]
+		author := "—"
+		if version.CreatedBy != "" {
+			if user, appErr := p.API.GetUser(version.CreatedBy); appErr == nil {
+				author = "@" + user.Username
+			}
+		}
+		lines = append(lines, fmt.Sprintf(tr.AdminTemplateHistoryEntry, version.Version, version.CreatedAt.Format("2006-01-02 15:04"), author))
+	}
+	return ephemeralResponse(strings.Join(lines, "\n")), nil
+}
+
+// executeTemplatePreview renders a template with sample data and sends the
+// HTML file to the admin's bot DM
+func (p *Plugin) executeTemplatePreview(args *model.CommandArgs, id string, versionArg []string) (*model.CommandResponse, *model.AppError) {
+	tr := p.translationsForUser(args.UserId)
+
+	tmpl, err := p.loadSignatureTemplate(id)
+	if err != nil {
+		p.API.LogError("failed to load signature template", "template_id", id, "err", err.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+	if tmpl == nil {
+		return ephemeralResponse(fmt.Sprintf(tr.AdminTemplateNotFound, id)), nil
+	}
+
+	version := tmpl.Current()
+	if len(versionArg) == 1 {
+		number, err := strconv.Atoi(versionArg[0])
+		var ok bool
+		if err == nil {
+			version, ok = tmpl.FindVersion(number)
+		}
+		if !ok {
+			return ephemeralResponse(fmt.Sprintf(tr.AdminTemplateVersionNotFound, id, versionArg[0])), nil
+		}
+	}
+
+	html, err := GenerateSignature(version.HTML, sampleSignatureData)
+	if err != nil {
+		return ephemeralResponse(err.Error()), nil
+	}
+
+	channel, appErr := p.API.GetDirectChannel(p.botUserID, args.UserId)
+	if appErr != nil {
+		p.API.LogError("failed to get DM channel", "user_id", args.UserId, "err", appErr.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+	fileInfo, appErr := p.API.UploadFile([]byte(html), channel.Id, fmt.Sprintf("%s_v%d_Preview.html", tmpl.ID, version.Version))
+	if appErr != nil {
+		p.API.LogError("failed to upload template preview", "template_id", id, "err", appErr.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+	if _, appErr := p.API.CreatePost(&model.Post{
+		UserId:    p.botUserID,
+		ChannelId: channel.Id,
+		Message:   fmt.Sprintf(tr.AdminTemplatePreview, tmpl.ID, version.Version),
+		FileIds:   []string{fileInfo.Id},
+	}); appErr != nil {
+		p.API.LogError("failed to post template preview", "template_id", id, "err", appErr.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+
+	return ephemeralResponse(tr.AdminTemplatePreviewSent), nil
+}
+
+// executeTemplateResend regenerates the signatures of everyone on a project
+// in the background and DMs the admin a summary
+func (p *Plugin) executeTemplateResend(args *model.CommandArgs, id string, dryRun bool) (*model.CommandResponse, *model.AppError) {
+	tr := p.translationsForUser(args.UserId)
+
+	if _, err := p.currentSignatureTemplate(id); err != nil {
+		return p.templateCommandError(args, id, "", err), nil
+	}
+
+	go func() {
+		result, err := p.resendProjectSignatures(id, dryRun)
+		if err != nil {
+			p.API.LogError("failed to resend signatures", "template_id", id, "err", err.Error())
+			if err := p.sendBotDM(args.UserId, tr.ErrorGeneral); err != nil {
+				p.API.LogWarn("resend: failed to send summary", "user_id", args.UserId, "err", err.Error())
+			}
+			return
+		}
+
+		summary := tr.AdminTemplateResendSummary
+		if dryRun {
+			summary = tr.AdminTemplateResendDryRunSummary
+		}
+		if err := p.sendBotDM(args.UserId, fmt.Sprintf(summary, id, result.Sent, result.Current, result.Failed)); err != nil {
+			p.API.LogWarn("resend: failed to send summary", "user_id", args.UserId, "err", err.Error())
+		}
+	}()
+
+	return ephemeralResponse(fmt.Sprintf(tr.AdminTemplateResendStarted, id)), nil
+}
+
+// executeTemplateEdit opens a dialog to create a template or add a version
+// to it. HTML longer than a dialog default can hold isn't pre-filled; leaving
+// it empty keeps the current HTML.
+func (p *Plugin) executeTemplateEdit(args *model.CommandArgs, id string) (*model.CommandResponse, *model.AppError) {
+	language := p.languageForUser(args.UserId)
+	tr := p.translationsFor(language)
+
+	if !templateIDPattern.MatchString(id) {
+		return ephemeralResponse(fmt.Sprintf(tr.AdminTemplateInvalid, fmt.Sprintf("id %q: use lowercase letters, digits and dashes", id))), nil
+	}
+	tmpl, err := p.loadSignatureTemplate(id)
+	if err != nil {
+		p.API.LogError("failed to load signature template", "template_id", id, "err", err.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+
+	callbackURL, err := p.pluginURL()
+	if err != nil {
+		p.API.LogError("pluginURL not configured", "err", err.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+
+	var current SignatureTemplateVersion
+	var name LocalizedText
+	if tmpl != nil {
+		current = tmpl.Current()
+		name = tmpl.Name
+	}
+	html := current.HTML
+	if len(html) > model.DialogElementTextareaMaxLength {
+		html = ""
+	}
+
+	dialog := model.OpenDialogRequest{
+		TriggerId: args.TriggerId,
+		URL:       callbackURL + "/submit-template",
+		Dialog: model.Dialog{
+			CallbackId:       id,
+			Title:            tr.DialogTemplateTitle,
+			IntroductionText: fmt.Sprintf(tr.DialogTemplateIntro, id),
+			Elements: []model.DialogElement{
+				{DisplayName: tr.DialogTemplateNameDE, Name: "name_de", Type: "text", Default: name["de"], Optional: true, HelpText: tr.DialogTemplateNameHelp},
+				{DisplayName: tr.DialogTemplateNameEN, Name: "name_en", Type: "text", Default: name["en"], Optional: true, HelpText: tr.DialogTemplateNameHelp},
+				{DisplayName: tr.DialogTemplateHTML, Name: "html", Type: "textarea", Default: html, Optional: tmpl != nil, MaxLength: maxTemplateSize, HelpText: tr.DialogTemplateHTMLHelp},
+				{DisplayName: tr.DialogTemplateOrganization, Name: "organization", Type: "text", Default: current.Organization, Optional: true, HelpText: tr.DialogTemplateTextHelp},
+				{DisplayName: tr.DialogTemplateAddress, Name: "address", Type: "text", Default: current.Address, Optional: true, HelpText: tr.DialogTemplateTextHelp},
+			},
+			SubmitLabel:    tr.DialogTemplateSubmit,
+			NotifyOnCancel: false,
+		},
+	}
+	if appErr := p.API.OpenInteractiveDialog(dialog); appErr != nil {
+		p.API.LogError("failed to open template dialog", "user_id", args.UserId, "err", appErr.Error())
+		return ephemeralResponse(tr.ErrorGeneral), nil
+	}
+	return &model.CommandResponse{}, nil
+}
+
+// handleTemplateSubmission saves the template dialog as a new version
+func (p *Plugin) handleTemplateSubmission(w http.ResponseWriter, r *http.Request, userID string) {
+	var submission model.SubmitDialogRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 2*maxTemplateSize)).Decode(&submission); err != nil {
+		p.API.LogError("failed to decode dialog submission", "err", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !p.verifyActingUser(w, r, userID, submission.UserId) {
+		return
+	}
+
+	tr := p.translationsForUser(userID)
+	writeResponse := func(resp *model.SubmitDialogResponse) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			p.API.LogError("failed to encode dialog response", "err", err.Error())
+		}
+	}
+
+	// The dialog may have been opened before the permission was revoked
+	if !p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+		writeResponse(&model.SubmitDialogResponse{Error: tr.AdminPermissionDenied})
+		return
+	}
+
+	id := submission.CallbackId
+	text := func(name string) string {
+		value, _ := submission.Submission[name].(string)
+		return strings.TrimSpace(value)
+	}
+	name := LocalizedText{}
+	for _, language := range []string{"de", "en"} {
+		if value := text("name_" + language); value != "" {
+			name[language] = value
+		}
+	}
+	version := SignatureTemplateVersion{HTML: text("html"), Organization: text("organization"), Address: text("address")}
+
+	if version.HTML == "" {
+		tmpl, err := p.loadSignatureTemplate(id)
+		if err != nil {
+			p.API.LogError("failed to load signature template", "template_id", id, "err", err.Error())
+			writeResponse(&model.SubmitDialogResponse{Error: tr.ErrorGeneral})
+			return
+		}
+		if tmpl == nil {
+			writeResponse(&model.SubmitDialogResponse{Errors: map[string]string{"html": tr.DialogFieldRequired}})
+			return
+		}
+		version.HTML = tmpl.Current().HTML
+	}
+
+	tmpl, err := p.saveSignatureTemplateVersion(id, name, version, userID)
+	if errors.Is(err, errTemplateInvalid) {
+		writeResponse(&model.SubmitDialogResponse{Error: fmt.Sprintf(tr.AdminTemplateInvalid, err.Error())})
+		return
+	}
+	if err != nil {
+		p.API.LogError("failed to save signature template", "template_id", id, "err", err.Error())
+		writeResponse(&model.SubmitDialogResponse{Error: tr.ErrorGeneral})
+		return
+	}
+
+	p.API.SendEphemeralPost(userID, &model.Post{
+		UserId:    p.botUserID,
+		ChannelId: submission.ChannelId,
+		Message:   fmt.Sprintf(tr.AdminTemplateSaved, tmpl.ID, tmpl.Current().Version),
+	})
+	writeResponse(&model.SubmitDialogResponse{})
+}
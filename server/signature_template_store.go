@@ -0,0 +1,352 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	signatureTemplateKVPrefix = "onboarding:signature_template:"
+
+	// updateTemplateAttempts bounds the compare-and-set retries of a template update
+	updateTemplateAttempts = 5
+)
+
+// templateIDPattern matches template ids, which double as project keys
+var templateIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,39}$`)
+
+var (
+	errTemplateNotFound        = errors.New("signature template not found")
+	errTemplateVersionNotFound = errors.New("signature template version not found")
+	// errTemplateInvalid wraps problems with a submitted template
+	errTemplateInvalid = errors.New("invalid signature template")
+)
+
+// SignatureTemplate is an admin-managed signature template for one project.
+// Every edit adds a version; the last version is the current one.
+type SignatureTemplate struct {
+	ID       string                     `json:"id"`
+	Name     LocalizedText              `json:"name"`
+	Retired  bool                       `json:"retired"`
+	Versions []SignatureTemplateVersion `json:"versions"`
+}
+
+// SignatureTemplateVersion is one revision of a template's HTML
+type SignatureTemplateVersion struct {
+	Version int    `json:"version"`
+	HTML    string `json:"html"`
+	// Organization and Address are the plain-text signature's lines below
+	// the position. They are versioned with the HTML so both files match.
+	Organization string    `json:"organization,omitempty"`
+	Address      string    `json:"address,omitempty"`
+	CreatedBy    string    `json:"created_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Current returns the latest version of the template
+func (t *SignatureTemplate) Current() SignatureTemplateVersion {
+	if len(t.Versions) == 0 {
+		return SignatureTemplateVersion{}
+	}
+	return t.Versions[len(t.Versions)-1]
+}
+
+// FindVersion returns a version of the template by number
+func (t *SignatureTemplate) FindVersion(version int) (SignatureTemplateVersion, bool) {
+	for _, v := range t.Versions {
+		if v.Version == version {
+			return v, true
+		}
+	}
+	return SignatureTemplateVersion{}, false
+}
+
+// sampleSignatureData fills template previews
+var sampleSignatureData = SignatureData{
+	FullName:     "Max Mustermann",
+	Position:     "Projektkoordinator*in",
+	Pronouns:     "sie",
+	Email:        "max.mustermann@example.org",
+	WorkNumber:   "+49 30 12345678",
+	MobileNumber: "+49 151 23456789",
+}
+
+// validateSignatureTemplateHTML checks that the HTML parses as a template and
+// renders with sample data
+func validateSignatureTemplateHTML(html string) error {
+	if strings.TrimSpace(html) == "" {
+		return errors.New("template HTML is empty")
+	}
+	if _, err := template.New("signature").Parse(html); err != nil {
+		return fmt.Errorf("parse template: %w", err)
+	}
+	if _, err := GenerateSignature(html, sampleSignatureData); err != nil {
+		return fmt.Errorf("render template: %w", err)
+	}
+	return nil
+}
+
+// ensureDefaultSignatureTemplates seeds the store with the built-in templates.
+// Templates that already exist, including retired ones, are left alone.
+func (p *Plugin) ensureDefaultSignatureTemplates() error {
+	for _, def := range defaultSignatureTemplates {
+		tmpl := &SignatureTemplate{
+			ID:   def.ID,
+			Name: def.Name,
+			Versions: []SignatureTemplateVersion{{
+				Version:      1,
+				HTML:         def.HTML,
+				Organization: defaultSignatureOrganization,
+				Address:      defaultSignatureAddress,
+				CreatedAt:    time.Now().UTC(),
+			}},
+		}
+		data, err := json.Marshal(tmpl)
+		if err != nil {
+			return err
+		}
+		if _, appErr := p.API.KVSetWithOptions(signatureTemplateKVPrefix+def.ID, data, model.PluginKVSetOptions{Atomic: true, OldValue: nil}); appErr != nil {
+			return fmt.Errorf("KVSetWithOptions: %w", appErr)
+		}
+	}
+	return nil
+}
+
+// loadSignatureTemplate returns a template, or nil if it doesn't exist
+func (p *Plugin) loadSignatureTemplate(id string) (*SignatureTemplate, error) {
+	data, appErr := p.API.KVGet(signatureTemplateKVPrefix + id)
+	if appErr != nil {
+		return nil, fmt.Errorf("KVGet: %w", appErr)
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var tmpl SignatureTemplate
+	if err := json.Unmarshal(data, &tmpl); err != nil {
+		return nil, err
+	}
+	return &tmpl, nil
+}
+
+// listSignatureTemplates returns every template, built-in ones first in their
+// usual order, then the rest by id
+func (p *Plugin) listSignatureTemplates() ([]*SignatureTemplate, error) {
+	const perPage = 200
+
+	var templates []*SignatureTemplate
+	for page := 0; ; page++ {
+		keys, appErr := p.API.KVList(page, perPage)
+		if appErr != nil {
+			return nil, fmt.Errorf("KVList: %w", appErr)
+		}
+		for _, key := range keys {
+			if !strings.HasPrefix(key, signatureTemplateKVPrefix) {
+				continue
+			}
+			tmpl, err := p.loadSignatureTemplate(strings.TrimPrefix(key, signatureTemplateKVPrefix))
+			if err != nil {
+				p.API.LogWarn("failed to load signature template", "key", key, "err", err.Error())
+				continue
+			}
+			if tmpl != nil {
+				templates = append(templates, tmpl)
+			}
+		}
+		if len(keys) < perPage {
+			break
+		}
+	}
+
+	order := map[string]int{}
+	for i, def := range defaultSignatureTemplates {
+		order[def.ID] = i
+	}
+	sort.SliceStable(templates, func(i, j int) bool {
+		oi, iDefault := order[templates[i].ID]
+		oj, jDefault := order[templates[j].ID]
+		switch {
+		case iDefault && jDefault:
+			return oi < oj
+		case iDefault != jDefault:
+			return iDefault
+		}
+		return templates[i].ID < templates[j].ID
+	})
+	return templates, nil
+}
+
+// activeSignatureTemplates returns the templates users can pick
+func (p *Plugin) activeSignatureTemplates() ([]*SignatureTemplate, error) {
+	templates, err := p.listSignatureTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	active := make([]*SignatureTemplate, 0, len(templates))
+	for _, tmpl := range templates {
+		if !tmpl.Retired {
+			active = append(active, tmpl)
+		}
+	}
+	return active, nil
+}
+
+// updateSignatureTemplate loads a template, applies change and stores it with
+// compare-and-set. change receives nil if the template doesn't exist yet and
+// returns the template to store.
+func (p *Plugin) updateSignatureTemplate(id string, change func(tmpl *SignatureTemplate) (*SignatureTemplate, error)) (*SignatureTemplate, error) {
+	key := signatureTemplateKVPrefix + id
+	for attempt := 0; attempt < updateTemplateAttempts; attempt++ {
+		oldData, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return nil, fmt.Errorf("KVGet: %w", appErr)
+		}
+
+		var current *SignatureTemplate
+		if oldData != nil {
+			current = &SignatureTemplate{}
+			if err := json.Unmarshal(oldData, current); err != nil {
+				return nil, err
+			}
+		}
+
+		updated, err := change(current)
+		if err != nil {
+			return nil, err
+		}
+		newData, err := json.Marshal(updated)
+		if err != nil {
+			return nil, err
+		}
+
+		ok, appErr := p.API.KVCompareAndSet(key, oldData, newData)
+		if appErr != nil {
+			return nil, fmt.Errorf("KVCompareAndSet: %w", appErr)
+		}
+		if ok {
+			return updated, nil
+		}
+	}
+
+	return nil, fmt.Errorf("update signature template: too many concurrent updates")
+}
+
+// saveSignatureTemplateVersion creates a template or adds a new version to
+// it. An empty name keeps the current name, and an empty organization or
+// address the one of the current version.
+func (p *Plugin) saveSignatureTemplateVersion(id string, name LocalizedText, version SignatureTemplateVersion, actorID string) (*SignatureTemplate, error) {
+	if !templateIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("%w: id %q: use lowercase letters, digits and dashes", errTemplateInvalid, id)
+	}
+	if err := validateSignatureTemplateHTML(version.HTML); err != nil {
+		return nil, fmt.Errorf("%w: %v", errTemplateInvalid, err)
+	}
+
+	return p.updateSignatureTemplate(id, func(tmpl *SignatureTemplate) (*SignatureTemplate, error) {
+		if tmpl == nil {
+			if len(name) == 0 {
+				return nil, fmt.Errorf("%w: a new template needs a name", errTemplateInvalid)
+			}
+			tmpl = &SignatureTemplate{ID: id}
+		}
+		if len(name) > 0 {
+			tmpl.Name = name
+		}
+		current := tmpl.Current()
+		if version.Organization == "" {
+			version.Organization = current.Organization
+		}
+		if version.Address == "" {
+			version.Address = current.Address
+		}
+		tmpl.Versions = append(tmpl.Versions, SignatureTemplateVersion{
+			Version:      current.Version + 1,
+			HTML:         version.HTML,
+			Organization: version.Organization,
+			Address:      version.Address,
+			CreatedBy:    actorID,
+			CreatedAt:    time.Now().UTC(),
+		})
+		return tmpl, nil
+	})
+}
+
+// setSignatureTemplateRetired retires or restores a template. Retired
+// templates stay in the store but are no longer offered in the dialog.
+func (p *Plugin) setSignatureTemplateRetired(id string, retired bool) (*SignatureTemplate, error) {
+	return p.updateSignatureTemplate(id, func(tmpl *SignatureTemplate) (*SignatureTemplate, error) {
+		if tmpl == nil {
+			return nil, errTemplateNotFound
+		}
+		tmpl.Retired = retired
+		return tmpl, nil
+	})
+}
+
+// rollbackSignatureTemplate adds a new version with the content of an older
+// one, so the history stays complete
+func (p *Plugin) rollbackSignatureTemplate(id string, version int, actorID string) (*SignatureTemplate, error) {
+	return p.updateSignatureTemplate(id, func(tmpl *SignatureTemplate) (*SignatureTemplate, error) {
+		if tmpl == nil {
+			return nil, errTemplateNotFound
+		}
+		old, ok := tmpl.FindVersion(version)
+		if !ok {
+			return nil, errTemplateVersionNotFound
+		}
+		tmpl.Versions = append(tmpl.Versions, SignatureTemplateVersion{
+			Version:      tmpl.Current().Version + 1,
+			HTML:         old.HTML,
+			Organization: old.Organization,
+			Address:      old.Address,
+			CreatedBy:    actorID,
+			CreatedAt:    time.Now().UTC(),
+		})
+		return tmpl, nil
+	})
+}
+
+// currentSignatureTemplate returns the current version of a project's
+// template. Retired templates count as missing.
+func (p *Plugin) currentSignatureTemplate(project string) (SignatureTemplateVersion, error) {
+	tmpl, err := p.loadSignatureTemplate(project)
+	if err != nil {
+		return SignatureTemplateVersion{}, err
+	}
+	if tmpl == nil || tmpl.Retired {
+		return SignatureTemplateVersion{}, errTemplateNotFound
+	}
+	return tmpl.Current(), nil
+}
+
+// generateSignature renders the user's signature with the current version of
+// their project's template
+func (p *Plugin) generateSignature(data SignatureData) (string, error) {
+	version, err := p.currentSignatureTemplate(data.Project)
+	if err != nil {
+		return "", err
+	}
+	return GenerateSignature(version.HTML, data)
+}
+
+// projectName returns the display name of a project's template, or the
+// project key if the template is gone
+func (p *Plugin) projectName(project, language string) string {
+	tmpl, err := p.loadSignatureTemplate(project)
+	if err != nil || tmpl == nil {
+		return project
+	}
+	if name := tmpl.Name.Get(language); name != "" {
+		return name
+	}
+	return project
+}
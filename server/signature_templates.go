@@ -2,22 +2,26 @@ package main
 
 import (
 	"bytes"
-	"fmt"
 	"html/template"
-	"strings"
 )
 
 // SignatureData holds all the information needed to generate an EOTO signature
 type SignatureData struct {
-	FullName   string
-	Position   string
-	Pronouns   string
-	Email      string
-	Project    string
-	WorkNumber string
+	FullName string `json:"full_name"`
+	Position string `json:"position"`
+	// Pronouns is a pronoun option id, "custom" for CustomPronouns, or free
+	// text from before the select existed
+	Pronouns       string `json:"pronouns,omitempty"`
+	CustomPronouns string `json:"custom_pronouns,omitempty"`
+	Email          string `json:"email"`
+	Project        string `json:"project"`
+	WorkNumber     string `json:"work_number,omitempty"`
+	// MobileNumber is optional; it renders below the work number
+	MobileNumber string `json:"mobile_number,omitempty"`
 }
 
-// EOTO Project-specific signature templates
+// EOTO Project-specific signature templates. They seed the template store
+// on first activation; after that admins manage templates in the KV store.
 const (
 	eachOneTemplate = `<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Transitional//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd">
 <table border="0">
@@ -38,6 +42,7 @@ const (
           Each One Teach One (EOTO) e.V.<br><br>
           Kamerunerstraße 16 | 13351 Berlin<br>
           {{.WorkNumber}}
+          {{.MobileNumber}}
           Email: <a href="mailto:{{.Email}}" style="color: #05576d; text-decoration:none;">{{.Email}}</a><br>
           <a href="http://each-one.de" style="color: #05576d; text-decoration:none;">Web: www.each-one.de</a>
           <a href="http://eoto-archiv.de" style="color: #05576d; text-decoration:none;">Web: www.eoto-archiv.de</a>
@@ -98,6 +103,7 @@ const (
           Each One Teach One (EOTO) e.V.<br><br>
           Kamerunerstraße 16 | 13351 Berlin<br>
           {{.WorkNumber}}
+          {{.MobileNumber}}
           Email: <a href="mailto:{{.Email}}" style="color: #05576d; text-decoration:none;">{{.Email}}</a><br>
           <a href="http://eoto-archiv.de" style="color: #05576d; text-decoration:none;">Web: www.eoto-archiv.de</a>
           <p style="font-family:Open Sans, Helvetica, Arial; color: #E08800; font-weight: regular; font-size: 12px; line-height: 18px; padding: 0px 0px 0px 10px;">
@@ -157,6 +163,7 @@ const (
           Each One Teach One (EOTO) e.V.<br><br>
           Kamerunerstraße 16 | 13351 Berlin<br>
           {{.WorkNumber}}
+          {{.MobileNumber}}
           Email: <a href="mailto:{{.Email}}" style="color: #05576d; text-decoration:none;">{{.Email}}</a><br>
           <a href="http://eoto-archiv.de" style="color: #05576d; text-decoration:none;">Web: www.eoto-archiv.de</a>
           <a href="http://cuz.berlin" style="color: #05576d; text-decoration:none;">Web: www.cuz.berlin</a>
@@ -217,6 +224,7 @@ const (
           Each One Teach One (EOTO) e.V.<br><br>
           Kamerunerstraße 16 | 13351 Berlin<br>
           {{.WorkNumber}}
+          {{.MobileNumber}}
           Email: <a href="mailto:{{.Email}}" style="color: #05576d; text-decoration:none;">{{.Email}}</a><br>
           <a href="http://eoto-archiv.de" style="color: #05576d; text-decoration:none;">Web: www.eoto-archiv.de</a>
           <p style="font-family:Open Sans, Helvetica, Arial; color: #E08800; font-weight: regular; font-size: 12px; line-height: 18px; padding: 0px 0px 0px 10px;">
@@ -276,6 +284,7 @@ const (
           Each One Teach One (EOTO) e.V.<br><br>
           Kamerunerstraße 16 | 13351 Berlin<br>
           {{.WorkNumber}}
+          {{.MobileNumber}}
           Email: <a href="mailto:{{.Email}}" style="color: #05576d; text-decoration:none;">{{.Email}}</a><br>
           <a href="http://eoto-archiv.de" style="color: #05576d; text-decoration:none;">Web: www.eoto-archiv.de</a>
           <p style="font-family:Open Sans, Helvetica, Arial; color: #E08800; font-weight: regular; font-size: 12px; line-height: 18px; padding: 0px 0px 0px 10px;">
@@ -335,6 +344,7 @@ const (
           Each One Teach One (EOTO) e.V.<br><br>
           Kamerunerstraße 16 | 13351 Berlin<br>
           {{.WorkNumber}}
+          {{.MobileNumber}}
           Email: <a href="mailto:{{.Email}}" style="color: #05576d; text-decoration:none;">{{.Email}}</a><br>
           <a href="http://eoto-archiv.de" style="color: #05576d; text-decoration:none;">Web: www.eoto-archiv.de</a>
           <p style="font-family:Open Sans, Helvetica, Arial; color: #E08800; font-weight: regular; font-size: 12px; line-height: 18px; padding: 0px 0px 0px 10px;">
@@ -376,43 +386,49 @@ const (
 </table>`
 )
 
-// GenerateSignature creates an HTML email signature based on the provided data and project
-func GenerateSignature(data SignatureData) (string, error) {
-	// Format pronouns like Python app does
-	formattedPronouns := formatPronouns(data.Pronouns)
+// Organization and address of the built-in templates, seeded with them for
+// the plain-text signature
+const (
+	defaultSignatureOrganization = "Each One Teach One (EOTO) e.V."
+	defaultSignatureAddress      = "Kamerunerstraße 16 | 13351 Berlin"
+)
 
-	// Select template based on project
-	var templateStr string
-	switch data.Project {
-	case "each-one":
-		templateStr = eachOneTemplate
-	case "community":
-		templateStr = communityTemplate
-	case "cuz":
-		templateStr = cuzTemplate
-	case "jugend":
-		templateStr = jugendTemplate
-	case "nar":
-		templateStr = narTemplate
-	case "afrolution":
-		templateStr = afrolutionTemplate
-	default:
-		templateStr = eachOneTemplate // Default to Each One
-	}
+// defaultSignatureTemplates are the built-in project templates, in the order
+// the dialog lists them
+var defaultSignatureTemplates = []struct {
+	ID   string
+	Name LocalizedText
+	HTML string
+}{
+	{"each-one", LocalizedText{"de": "Each One", "en": "Each One"}, eachOneTemplate},
+	{"community", LocalizedText{"de": "CommUnity", "en": "CommUnity"}, communityTemplate},
+	{"cuz", LocalizedText{"de": "CommUnity Zentrum (CUZ)", "en": "CommUnity Zentrum (CUZ)"}, cuzTemplate},
+	{"jugend", LocalizedText{"de": "Jugendangebote", "en": "Youth Programs"}, jugendTemplate},
+	{"nar", LocalizedText{"de": "Netzwerk-Antirassismus (NAR)", "en": "Network-Antiracism (NAR)"}, narTemplate},
+	{"afrolution", LocalizedText{"de": "Afrolution", "en": "Afrolution"}, afrolutionTemplate},
+}
 
-	// Prepare data for template
+// GenerateSignature renders an HTML email signature template with the provided data
+func GenerateSignature(templateStr string, data SignatureData) (string, error) {
+	// Render pronouns by the rule table
+	formattedPronouns := formatPronouns(data)
+
+	// Prepare data for template. Phone numbers render as complete lines in
+	// house style, or not at all when empty.
 	templateData := struct {
-		FullName   string
-		Position   string
-		Pronouns   string
-		Email      string
-		WorkNumber string
+		FullName     string
+		Position     string
+		Pronouns     string
+		Email        string
+		WorkNumber   template.HTML
+		MobileNumber template.HTML
 	}{
-		FullName:   data.FullName,
-		Position:   data.Position,
-		Pronouns:   formattedPronouns,
-		Email:      data.Email,
-		WorkNumber: data.WorkNumber,
+		FullName:     data.FullName,
+		Position:     data.Position,
+		Pronouns:     formattedPronouns,
+		Email:        data.Email,
+		WorkNumber:   phoneLine(workNumberLabel, data.WorkNumber),
+		MobileNumber: phoneLine(mobileNumberLabel, data.MobileNumber),
 	}
 
 	// Parse and execute template
@@ -426,50 +442,14 @@ func GenerateSignature(data SignatureData) (string, error) {
 		return "", err
 	}
 
-	signature := buf.String()
-
-	// Remove work_number line if empty (like Python app does)
-	if data.WorkNumber == "" {
-		lines := strings.Split(signature, "\n")
-		var filteredLines []string
-		for _, line := range lines {
-			if !strings.Contains(line, "{{.WorkNumber}}") && strings.TrimSpace(line) != "" {
-				filteredLines = append(filteredLines, line)
-			}
-		}
-		signature = strings.Join(filteredLines, "\n")
-	} else {
-		// Add line break after work number if it exists
-		signature = strings.ReplaceAll(signature, data.WorkNumber, data.WorkNumber+"<br>")
-	}
-
-	return signature, nil
+	return buf.String(), nil
 }
 
-// formatPronouns formats pronouns like the Python app:
-// "er/ihm / he/him" -> "Pronomen er/ihm - pronouns he/him"
-// "Keine Pronomen / No Pronouns" -> "Keine Pronomen / No Pronouns"
-func formatPronouns(pronouns string) string {
-	if pronouns == "" {
+// phoneLine renders "Tel.: +49 30 1234 5678<br>", or nothing for an empty number
+func phoneLine(label, number string) template.HTML {
+	number = normalizePhoneNumber(number)
+	if number == "" {
 		return ""
 	}
-
-	// Check if it contains the split pattern
-	if strings.Contains(pronouns, " / ") {
-		parts := strings.Split(pronouns, " / ")
-		if len(parts) == 2 {
-			germanPart := strings.TrimSpace(parts[0])
-			englishPart := strings.TrimSpace(parts[1])
-
-			// Check if it's the "No Pronouns" case
-			if strings.ToLower(germanPart) == "keine pronomen" || strings.ToLower(englishPart) == "no pronouns" {
-				return pronouns
-			}
-
-			// Format as: Pronomen {german} - pronouns {english}
-			return fmt.Sprintf("Pronomen %s - pronouns %s", germanPart, englishPart)
-		}
-	}
-
-	return pronouns
+	return template.HTML(template.HTMLEscapeString(label+" "+number) + "<br>")
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+)
+
+// textSignatureTemplate lays out the plain-text signature like the HTML
+// templates: name, pronouns, position and project, organization, address,
+// contact lines. Empty optional lines are left out.
+const textSignatureTemplate = `{{.FullName}}
+{{if .Pronouns}}{{.Pronouns}}
+{{end}}{{.Position}} - {{.ProjectName}}
+{{if .Organization}}{{.Organization}}
+{{end}}
+{{if .Address}}{{.Address}}
+{{end}}{{range .ContactLines}}{{.}}
+{{end}}`
+
+var parsedTextSignatureTemplate = template.Must(template.New("signature_text").Parse(textSignatureTemplate))
+
+// GenerateTextSignature renders the plain-text signature from the same
+// SignatureData as GenerateSignature. projectName is the display name of
+// the data's project; organization and address come from the template
+// version the HTML file is built with.
+func GenerateTextSignature(data SignatureData, projectName string, version SignatureTemplateVersion) (string, error) {
+	var contactLines []string
+	for _, phone := range []struct{ label, number string }{
+		{workNumberLabel, data.WorkNumber},
+		{mobileNumberLabel, data.MobileNumber},
+	} {
+		if number := normalizePhoneNumber(phone.number); number != "" {
+			contactLines = append(contactLines, phone.label+" "+number)
+		}
+	}
+	contactLines = append(contactLines, "Email: "+data.Email)
+
+	templateData := struct {
+		FullName     string
+		Pronouns     string
+		Position     string
+		ProjectName  string
+		Organization string
+		Address      string
+		ContactLines []string
+	}{
+		FullName:     strings.TrimSpace(data.FullName),
+		Pronouns:     formatPronouns(data),
+		Position:     strings.TrimSpace(data.Position),
+		ProjectName:  projectName,
+		Organization: strings.TrimSpace(version.Organization),
+		Address:      strings.TrimSpace(version.Address),
+		ContactLines: contactLines,
+	}
+
+	var buf bytes.Buffer
+	if err := parsedTextSignatureTemplate.Execute(&buf, templateData); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
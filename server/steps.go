@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// LocalizedText maps a language code (e.g. "de", "en") to a translated string
+type LocalizedText map[string]string
+
+// Get returns the text for the given language, falling back to English,
+// German and finally any available translation.
+func (l LocalizedText) Get(language string) string {
+	for _, lang := range []string{language, "en", "de"} {
+		if text := l[lang]; text != "" {
+			return text
+		}
+	}
+	for _, text := range l {
+		if text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// StepLink is a documentation link shown below a step description
+type StepLink struct {
+	Label LocalizedText `json:"label"`
+	URL   string        `json:"url"`
+}
+
+// StepDefinition describes one checklist step as configured by admins
+type StepDefinition struct {
+	ID          string        `json:"id"`
+	Title       LocalizedText `json:"title"`
+	Description LocalizedText `json:"description"`
+	Links       []StepLink    `json:"links,omitempty"`
+	ButtonLabel LocalizedText `json:"button_label"`
+	// Actions lists extra buttons shown before the completion button
+	Actions []string `json:"actions,omitempty"`
+	// Checks lists conditions the plugin verifies; see parseStepCheck
+	Checks []string `json:"checks,omitempty"`
+}
+
+// Extra step actions that can be referenced from a step definition
+const (
+	stepActionSignature    = "signature"
+	stepActionIntroduction = "introduction"
+	stepActionPolicies     = "policies"
+)
+
+var knownStepActions = map[string]struct{}{
+	stepActionSignature:    {},
+	stepActionIntroduction: {},
+	stepActionPolicies:     {},
+}
+
+// parseStepDefinitions parses and validates the ChecklistSteps setting
+func parseStepDefinitions(raw string) ([]StepDefinition, error) {
+	var steps []StepDefinition
+	if err := json.Unmarshal([]byte(raw), &steps); err != nil {
+		return nil, fmt.Errorf("parse checklist steps: %w", err)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("checklist steps: at least one step is required")
+	}
+
+	seen := make(map[string]struct{}, len(steps))
+	for i, step := range steps {
+		if strings.TrimSpace(step.ID) == "" {
+			return nil, fmt.Errorf("checklist step %d: id is required", i+1)
+		}
+		if _, ok := seen[step.ID]; ok {
+			return nil, fmt.Errorf("checklist step %q: duplicate id", step.ID)
+		}
+		seen[step.ID] = struct{}{}
+
+		if step.Title.Get("en") == "" {
+			return nil, fmt.Errorf("checklist step %q: title is required", step.ID)
+		}
+		for _, link := range step.Links {
+			if link.URL == "" {
+				return nil, fmt.Errorf("checklist step %q: link url is required", step.ID)
+			}
+		}
+		for _, action := range step.Actions {
+			if _, ok := knownStepActions[action]; !ok {
+				return nil, fmt.Errorf("checklist step %q: unknown action %q", step.ID, action)
+			}
+		}
+		for _, check := range step.Checks {
+			if _, err := parseStepCheck(check); err != nil {
+				return nil, fmt.Errorf("checklist step %q: %w", step.ID, err)
+			}
+		}
+	}
+
+	return steps, nil
+}
+
+// getSteps returns the checklist steps parsed in OnConfigurationChange, or
+// the built-in defaults when the ChecklistSteps setting is empty or invalid.
+func (p *Plugin) getSteps() []StepDefinition {
+	p.settings.RLock()
+	defer p.settings.RUnlock()
+	if p.settings.steps == nil {
+		return defaultSteps
+	}
+	return p.settings.steps
+}
+
+// findStep looks up a step definition by its id
+func findStep(steps []StepDefinition, id string) (StepDefinition, bool) {
+	for _, step := range steps {
+		if step.ID == id {
+			return step, true
+		}
+	}
+	return StepDefinition{}, false
+}
+
+// hasStepAction reports whether the step shows the extra button
+func hasStepAction(step StepDefinition, action string) bool {
+	for _, a := range step.Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// renderStepText builds the attachment text for a step in the given language
+func renderStepText(step StepDefinition, language string) string {
+	links := make([]string, 0, len(step.Links))
+	for _, link := range step.Links {
+		label := link.Label.Get(language)
+		if label == "" {
+			label = link.URL
+		}
+		links = append(links, fmt.Sprintf("[%s](%s)", label, link.URL))
+	}
+	return step.Description.Get(language) + strings.Join(links, " · ")
+}
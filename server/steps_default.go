@@ -0,0 +1,207 @@
+package main
+
+// defaultSteps is the built-in checklist used when no ChecklistSteps setting is configured
+var defaultSteps = []StepDefinition{
+	{
+		ID: "accounts",
+		Title: LocalizedText{
+			"en": "Accounts & Access",
+			"de": "Konten & Zugang",
+		},
+		Description: LocalizedText{
+			"en": "Make sure you can log in everywhere you need to:\n" +
+				"- Google Workspace (EOTO email address issued & tested)\n" +
+				"- Nextcloud (files & shared team folders)\n" +
+				"- Timebutler (time tracking / attendance)\n" +
+				"- Mattermost (you're here 🎉)\n" +
+				"- Any role-specific tools (e.g. CRM, finance tools)\n\n" +
+				"More details: ",
+			"de": "Stelle sicher, dass du dich überall anmelden kannst, wo du es benötigst:\n" +
+				"- Google Workspace (EOTO E-Mail-Adresse ausgegeben & getestet)\n" +
+				"- Nextcloud (Dateien & gemeinsame Team-Ordner)\n" +
+				"- Timebutler (Zeiterfassung / Anwesenheit)\n" +
+				"- Mattermost (du bist hier 🎉)\n" +
+				"- Alle rollenspezifischen Tools (z.B. CRM, Finanztools)\n\n" +
+				"Mehr Details: ",
+		},
+		Links: []StepLink{
+			{
+				Label: LocalizedText{"en": "Accounts & Access Guide", "de": "Konten & Zugang Leitfaden"},
+				URL:   "https://outline.akinlosotu.tech",
+			},
+		},
+		ButtonLabel: LocalizedText{
+			"en": "Mark Accounts Ready",
+			"de": "Konten bereit markieren",
+		},
+	},
+	{
+		ID: "profile",
+		Title: LocalizedText{
+			"en": "Complete Your Profile",
+			"de": "Vervollständige dein Profil",
+		},
+		Description: LocalizedText{
+			"en": "Help colleagues recognize and reach you easily:\n" +
+				"- Upload a clear profile photo\n" +
+				"- Add your full name and pronouns (if desired)\n" +
+				"- Set your job title & department\n" +
+				"- Configure your timezone and working hours\n" +
+				"- Generate your email signature ✉️\n\n" +
+				"Quick reference: ",
+			"de": "Hilf Kollegen, dich leicht zu erkennen und zu erreichen:\n" +
+				"- Lade ein klares Profilfoto hoch\n" +
+				"- Füge deinen vollständigen Namen und Pronomen hinzu (falls gewünscht)\n" +
+				"- Lege deinen Jobtitel & deine Abteilung fest\n" +
+				"- Stelle deine Zeitzone und Arbeitszeiten ein\n" +
+				"- Generiere deine E-Mail-Signatur ✉️\n\n" +
+				"Schnellreferenz: ",
+		},
+		Links: []StepLink{
+			{
+				Label: LocalizedText{"en": "Mattermost Profile & Notifications", "de": "Mattermost Profil & Benachrichtigungen"},
+				URL:   "https://outline.akinlosotu.tech",
+			},
+		},
+		ButtonLabel: LocalizedText{
+			"en": "Mark Profile Complete",
+			"de": "Profil vollständig markieren",
+		},
+		Actions: []string{stepActionSignature},
+		Checks:  []string{stepCheckProfilePhoto, stepCheckFullName, stepCheckPosition, stepCheckTimezone},
+	},
+	{
+		ID: "channels",
+		Title: LocalizedText{
+			"en": "Communication Channels",
+			"de": "Kommunikationskanäle",
+		},
+		Description: LocalizedText{
+			"en": "Join the spaces where information flows:\n" +
+				"- `#announcements` — organization-wide updates\n" +
+				"- `#helpdesk` — IT support & quick questions\n" +
+				"- `#introductions` — say hello to everyone\n" +
+				"- Your team / project channels (ask your manager)\n\n" +
+				"Guidelines: ",
+			"de": "Tritt den Räumen bei, in denen Informationen fließen:\n" +
+				"- `#announcements` — organisationsweite Updates\n" +
+				"- `#helpdesk` — IT-Support & schnelle Fragen\n" +
+				"- `#introductions` — sag allen Hallo\n" +
+				"- Deine Team- / Projektkanäle (frage deinen Manager)\n\n" +
+				"Richtlinien: ",
+		},
+		Links: []StepLink{
+			{
+				Label: LocalizedText{"en": "Communication & Channels", "de": "Kommunikation & Kanäle"},
+				URL:   "https://outline.akinlosotu.tech",
+			},
+		},
+		ButtonLabel: LocalizedText{
+			"en": "Mark Channels Joined",
+			"de": "Kanäle beigetreten markieren",
+		},
+		Checks: []string{
+			stepCheckChannelMember + ":announcements",
+			stepCheckChannelMember + ":helpdesk",
+			stepCheckChannelMember + ":introductions",
+		},
+	},
+	{
+		ID: "tools",
+		Title: LocalizedText{
+			"en": "Tools & Equipment",
+			"de": "Tools & Ausrüstung",
+		},
+		Description: LocalizedText{
+			"en": "Confirm your hardware and core tools are ready:\n" +
+				"- Laptop received, boots correctly, and you can log in\n" +
+				"- Wi-Fi access at your usual work location(s)\n" +
+				"- Nextcloud client installed (if required)\n" +
+				"- Email & calendar working on your primary device\n" +
+				"- Required VPN or remote access configured\n\n" +
+				"See: ",
+			"de": "Bestätige, dass deine Hardware und Kerntools bereit sind:\n" +
+				"- Laptop erhalten, startet korrekt und du kannst dich anmelden\n" +
+				"- WLAN-Zugang an deinem üblichen Arbeitsort(en)\n" +
+				"- Nextcloud-Client installiert (falls erforderlich)\n" +
+				"- E-Mail & Kalender funktionieren auf deinem Hauptgerät\n" +
+				"- Erforderliches VPN oder Fernzugriff konfiguriert\n\n" +
+				"Siehe: ",
+		},
+		Links: []StepLink{
+			{
+				Label: LocalizedText{"en": "Devices & IT Setup", "de": "Geräte & IT-Einrichtung"},
+				URL:   "https://outline.akinlosotu.tech",
+			},
+		},
+		ButtonLabel: LocalizedText{
+			"en": "Mark Tools Ready",
+			"de": "Tools bereit markieren",
+		},
+	},
+	{
+		ID: "policies",
+		Title: LocalizedText{
+			"en": "Work Practices & Policies",
+			"de": "Arbeitsweisen & Richtlinien",
+		},
+		Description: LocalizedText{
+			"en": "Take an initial pass through how we work at EOTO:\n" +
+				"- Working hours, flextime, and vacation process\n" +
+				"- Privacy & data protection basics (GDPR awareness)\n" +
+				"- Communication expectations (response times, DM vs. channels)\n" +
+				"- How we store and share files (Nextcloud structure)\n\n" +
+				"Start here: ",
+			"de": "Mache einen ersten Durchgang durch die Arbeitsweise bei EOTO:\n" +
+				"- Arbeitszeiten, Gleitzeit und Urlaubsprozess\n" +
+				"- Datenschutz & Datenschutz-Grundlagen (DSGVO-Bewusstsein)\n" +
+				"- Kommunikationserwartungen (Antwortzeiten, DM vs. Kanäle)\n" +
+				"- Wie wir Dateien speichern und teilen (Nextcloud-Struktur)\n\n" +
+				"Beginne hier: ",
+		},
+		Links: []StepLink{
+			{
+				Label: LocalizedText{"en": "EOTO Handbook", "de": "EOTO Handbuch"},
+				URL:   "https://outline.akinlosotu.tech",
+			},
+		},
+		ButtonLabel: LocalizedText{
+			"en": "Mark Policies Reviewed",
+			"de": "Richtlinien überprüft markieren",
+		},
+		Actions: []string{stepActionPolicies},
+	},
+	{
+		ID: "intro",
+		Title: LocalizedText{
+			"en": "People & Check-ins",
+			"de": "Menschen & Check-ins",
+		},
+		Description: LocalizedText{
+			"en": "Make sure you're connected with the right people:\n" +
+				"- Brief introduction post in `#introductions`\n" +
+				"- 1:1 intro meeting with your manager (scheduled)\n" +
+				"- Check-in with your onboarding buddy (if assigned)\n" +
+				"- Add key people to your favorites in Mattermost\n\n" +
+				"Tips: ",
+			"de": "Stelle sicher, dass du mit den richtigen Menschen verbunden bist:\n" +
+				"- Kurzer Vorstellungsbeitrag in `#introductions`\n" +
+				"- 1:1-Vorstellung mit deinem Manager (geplant)\n" +
+				"- Check-in mit deinem Onboarding-Buddy (falls zugewiesen)\n" +
+				"- Füge wichtige Personen zu deinen Favoriten in Mattermost hinzu\n\n" +
+				"Tipps: ",
+		},
+		Links: []StepLink{
+			{
+				Label: LocalizedText{"en": "Onboarding & Collaboration at EOTO", "de": "Onboarding & Zusammenarbeit bei EOTO"},
+				URL:   "https://outline.akinlosotu.tech",
+			},
+		},
+		ButtonLabel: LocalizedText{
+			"en": "Mark Intros Done",
+			"de": "Vorstellungen erledigt markieren",
+		},
+		Actions: []string{stepActionIntroduction},
+		Checks:  []string{stepCheckChannelPost + ":introductions"},
+	},
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// TrackRules decide which users a track applies to. Every non-empty rule
+// must match; within a rule, any listed value is enough.
+type TrackRules struct {
+	Teams     []string          `json:"teams,omitempty"`
+	Roles     []string          `json:"roles,omitempty"`
+	UserProps map[string]string `json:"user_props,omitempty"`
+	// Users lists usernames an admin has assigned to this track explicitly
+	Users []string `json:"users,omitempty"`
+}
+
+// TrackDefinition is a named set of checklist steps for a role or project
+type TrackDefinition struct {
+	ID    string        `json:"id"`
+	Name  LocalizedText `json:"name"`
+	Steps []string      `json:"steps"`
+	Rules TrackRules    `json:"rules"`
+}
+
+// parseTrackDefinitions parses the OnboardingTracks setting and checks that
+// every referenced step exists.
+func parseTrackDefinitions(raw string, steps []StepDefinition) ([]TrackDefinition, error) {
+	var tracks []TrackDefinition
+	if err := json.Unmarshal([]byte(raw), &tracks); err != nil {
+		return nil, fmt.Errorf("parse onboarding tracks: %w", err)
+	}
+
+	seen := make(map[string]struct{}, len(tracks))
+	for i, track := range tracks {
+		if strings.TrimSpace(track.ID) == "" {
+			return nil, fmt.Errorf("onboarding track %d: id is required", i+1)
+		}
+		if _, ok := seen[track.ID]; ok {
+			return nil, fmt.Errorf("onboarding track %q: duplicate id", track.ID)
+		}
+		seen[track.ID] = struct{}{}
+
+		if len(track.Steps) == 0 {
+			return nil, fmt.Errorf("onboarding track %q: at least one step is required", track.ID)
+		}
+		for _, stepID := range track.Steps {
+			if _, ok := findStep(steps, stepID); !ok {
+				return nil, fmt.Errorf("onboarding track %q: unknown step %q", track.ID, stepID)
+			}
+		}
+	}
+
+	return tracks, nil
+}
+
+// getTracks returns the onboarding tracks parsed in OnConfigurationChange.
+// An empty or invalid setting means everyone gets the full checklist.
+func (p *Plugin) getTracks() []TrackDefinition {
+	p.settings.RLock()
+	defer p.settings.RUnlock()
+	return p.settings.tracks
+}
+
+// findTrack looks up a track definition by its id
+func findTrack(tracks []TrackDefinition, id string) (TrackDefinition, bool) {
+	for _, track := range tracks {
+		if track.ID == id {
+			return track, true
+		}
+	}
+	return TrackDefinition{}, false
+}
+
+// selectTrack returns the id of the first track whose rules match the user,
+// or "" when no track applies. hasTeams reports whether the user belonged to
+// a team, i.e. whether team and team role rules could match.
+func (p *Plugin) selectTrack(user *model.User) (track string, hasTeams bool) {
+	tracks := p.getTracks()
+	if len(tracks) == 0 {
+		return "", false
+	}
+
+	teamNames := map[string]struct{}{}
+	roles := map[string]struct{}{}
+	for _, role := range strings.Fields(user.Roles) {
+		roles[role] = struct{}{}
+	}
+
+	if teams, appErr := p.API.GetTeamsForUser(user.Id); appErr == nil {
+		for _, team := range teams {
+			teamNames[team.Name] = struct{}{}
+		}
+	} else {
+		p.API.LogWarn("failed to get teams for track selection", "user_id", user.Id, "err", appErr.Error())
+	}
+
+	if members, appErr := p.API.GetTeamMembersForUser(user.Id, 0, 200); appErr == nil {
+		for _, member := range members {
+			for _, role := range member.GetRoles() {
+				roles[role] = struct{}{}
+			}
+			if member.SchemeUser {
+				roles[model.TeamUserRoleId] = struct{}{}
+			}
+			if member.SchemeAdmin {
+				roles[model.TeamAdminRoleId] = struct{}{}
+			}
+		}
+	} else {
+		p.API.LogWarn("failed to get team memberships for track selection", "user_id", user.Id, "err", appErr.Error())
+	}
+
+	for _, track := range tracks {
+		if trackMatches(track.Rules, user, teamNames, roles) {
+			return track.ID, len(teamNames) > 0
+		}
+	}
+	return "", len(teamNames) > 0
+}
+
+// reselectTrackOnTeamJoin selects the track again when a user whose track
+// was chosen before they had any team joins one. Accounts are created
+// without team memberships, so team and team role rules only match now.
+func (p *Plugin) reselectTrackOnTeamJoin(user *model.User) error {
+	state, err := p.loadState(user.Id)
+	if err != nil {
+		return err
+	}
+	if state == nil || state.TrackFinal || !state.CompletedAt.IsZero() {
+		return nil
+	}
+
+	track, hasTeams := p.selectTrack(user)
+	if !hasTeams {
+		return nil
+	}
+	previous := state.Track
+	// An admin may have assigned a track in the meantime
+	if err := p.updateState(state, func(s *OnboardingState) {
+		if !s.TrackFinal {
+			s.Track = track
+			s.TrackFinal = true
+		}
+	}); err != nil {
+		return err
+	}
+	changed := state.Track != previous
+	if changed {
+		p.refreshChecklistPost(user, state)
+		p.verifyUserSteps(state)
+	}
+	return nil
+}
+
+// trackMatches evaluates track rules. A track without rules matches everyone.
+func trackMatches(rules TrackRules, user *model.User, teamNames, roles map[string]struct{}) bool {
+	if len(rules.Users) > 0 && !containsFold(rules.Users, user.Username) {
+		return false
+	}
+	if len(rules.Teams) > 0 && !anyInSet(rules.Teams, teamNames) {
+		return false
+	}
+	if len(rules.Roles) > 0 && !anyInSet(rules.Roles, roles) {
+		return false
+	}
+	for key, value := range rules.UserProps {
+		if user.Props[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func anyInSet(values []string, set map[string]struct{}) bool {
+	for _, value := range values {
+		if _, ok := set[value]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, needle string) bool {
+	needle = strings.TrimPrefix(needle, "@")
+	for _, value := range values {
+		if strings.EqualFold(strings.TrimPrefix(value, "@"), needle) {
+			return true
+		}
+	}
+	return false
+}
+
+// stepsForState returns the checklist steps for the user's track, or the
+// full checklist when no (longer valid) track is stored.
+func (p *Plugin) stepsForState(state *OnboardingState) []StepDefinition {
+	steps := p.getSteps()
+	if state == nil || state.Track == "" {
+		return steps
+	}
+
+	track, ok := findTrack(p.getTracks(), state.Track)
+	if !ok {
+		return steps
+	}
+
+	trackSteps := make([]StepDefinition, 0, len(track.Steps))
+	for _, stepID := range track.Steps {
+		if step, ok := findStep(steps, stepID); ok {
+			trackSteps = append(trackSteps, step)
+		}
+	}
+	return trackSteps
+}
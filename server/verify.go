@@ -0,0 +1,328 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// Checks a step definition can list. Channel checks name the channel after a
+// colon, e.g. "channel_member:announcements".
+const (
+	stepCheckProfilePhoto  = "profile_photo"
+	stepCheckFullName      = "full_name"
+	stepCheckPosition      = "position"
+	stepCheckTimezone      = "timezone"
+	stepCheckChannelMember = "channel_member"
+	stepCheckChannelPost   = "channel_post"
+
+	// stepCheckPolicies is implied by the policies action, see checksForStep
+	stepCheckPolicies = "policies"
+	// stepCheckQuiz is implied by a quiz attached to the step
+	stepCheckQuiz = "quiz"
+)
+
+// errCheckChannelNotFound means a channel check names a channel that no team
+// of the user has, so the check can't be evaluated
+var errCheckChannelNotFound = errors.New("check channel not found")
+
+// stepCheck is one parsed entry of a step's checks
+type stepCheck struct {
+	Kind string
+	// Channel is the channel name of channel checks
+	Channel string
+	// Step is the step id of quiz checks
+	Step string
+}
+
+// String returns the check as written in a step definition
+func (c stepCheck) String() string {
+	if c.Channel != "" {
+		return c.Kind + ":" + c.Channel
+	}
+	return c.Kind
+}
+
+// parseStepCheck parses a check like "timezone" or "channel_post:introductions"
+func parseStepCheck(raw string) (stepCheck, error) {
+	kind, channel, hasChannel := strings.Cut(strings.TrimSpace(raw), ":")
+	switch kind {
+	case stepCheckProfilePhoto, stepCheckFullName, stepCheckPosition, stepCheckTimezone:
+		if hasChannel {
+			return stepCheck{}, fmt.Errorf("check %q takes no channel", kind)
+		}
+	case stepCheckChannelMember, stepCheckChannelPost:
+		channel = strings.TrimPrefix(strings.TrimSpace(channel), "~")
+		if channel == "" {
+			return stepCheck{}, fmt.Errorf("check %q needs a channel name", kind)
+		}
+	default:
+		return stepCheck{}, fmt.Errorf("unknown check %q", raw)
+	}
+	return stepCheck{Kind: kind, Channel: channel}, nil
+}
+
+// stepChecks returns the parsed checks of a step. Definitions are validated
+// when loaded, so invalid entries are only skipped here.
+func stepChecks(step StepDefinition) []stepCheck {
+	checks := make([]stepCheck, 0, len(step.Checks))
+	for _, raw := range step.Checks {
+		if check, err := parseStepCheck(raw); err == nil {
+			checks = append(checks, check)
+		}
+	}
+	return checks
+}
+
+// checksForStep returns the step's checks. Steps with the policies action
+// also check that the user accepted every policy of their track, and steps
+// with a quiz that the user passed it.
+func (p *Plugin) checksForStep(step StepDefinition, state *OnboardingState) []stepCheck {
+	checks := stepChecks(step)
+	if hasStepAction(step, stepActionPolicies) && len(p.policiesForState(state)) > 0 {
+		checks = append(checks, stepCheck{Kind: stepCheckPolicies})
+	}
+	if _, ok := findQuiz(p.getQuizzes(), step.ID); ok {
+		checks = append(checks, stepCheck{Kind: stepCheckQuiz, Step: step.ID})
+	}
+	return checks
+}
+
+// verifyOpenSteps evaluates the checks of the user's open steps and completes
+// the steps whose checks all pass. It returns the completed step ids and what
+// is still missing for the others. Steps whose checks couldn't be evaluated
+// appear in neither, so they never block manual completion.
+func (p *Plugin) verifyOpenSteps(user *model.User, state *OnboardingState) ([]string, map[string][]stepCheck) {
+	var completed []string
+	missing := map[string][]stepCheck{}
+	if user.DeleteAt != 0 {
+		return completed, missing
+	}
+
+	for _, step := range p.stepsForState(state) {
+		checks := p.checksForStep(step, state)
+		if state.CompletedSteps[step.ID] || len(checks) == 0 {
+			continue
+		}
+
+		failed, err := p.failedChecks(user, state, checks)
+		if err != nil {
+			if !errors.Is(err, errCheckChannelNotFound) {
+				p.API.LogWarn("failed to verify step", "user_id", user.Id, "step", step.ID, "err", err.Error())
+			}
+			continue
+		}
+		if len(failed) > 0 {
+			missing[step.ID] = failed
+			continue
+		}
+
+		if err := p.setStepCompleted(state, step.ID, true, p.botUserID, eventSourceVerifier); err != nil {
+			p.API.LogError("failed to complete verified step", "user_id", user.Id, "step", step.ID, "err", err.Error())
+			continue
+		}
+		completed = append(completed, step.ID)
+	}
+	return completed, missing
+}
+
+// verifyUserSteps runs the checks for a user outside a button click and
+// updates the checklist post when a step was completed
+func (p *Plugin) verifyUserSteps(state *OnboardingState) {
+	if !p.hasOpenCheckedSteps(state) {
+		return
+	}
+
+	user, appErr := p.API.GetUser(state.UserID)
+	if appErr != nil {
+		p.API.LogWarn("failed to get user", "user_id", state.UserID, "err", appErr.Error())
+		return
+	}
+	if completed, _ := p.verifyOpenSteps(user, state); len(completed) > 0 {
+		p.refreshChecklistPost(user, state)
+	}
+}
+
+// hasOpenCheckedSteps reports whether any open step of the user has checks
+func (p *Plugin) hasOpenCheckedSteps(state *OnboardingState) bool {
+	for _, step := range p.stepsForState(state) {
+		if !state.CompletedSteps[step.ID] && len(p.checksForStep(step, state)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// failedChecks returns the checks the user doesn't pass yet
+func (p *Plugin) failedChecks(user *model.User, state *OnboardingState, checks []stepCheck) ([]stepCheck, error) {
+	var failed []stepCheck
+	for _, check := range checks {
+		passed, err := p.checkPasses(user, state, check)
+		if err != nil {
+			return nil, fmt.Errorf("check %s: %w", check.Kind, err)
+		}
+		if !passed {
+			failed = append(failed, check)
+		}
+	}
+	return failed, nil
+}
+
+func (p *Plugin) checkPasses(user *model.User, state *OnboardingState, check stepCheck) (bool, error) {
+	switch check.Kind {
+	case stepCheckProfilePhoto:
+		return user.LastPictureUpdate > 0, nil
+	case stepCheckFullName:
+		return strings.TrimSpace(user.FirstName) != "" && strings.TrimSpace(user.LastName) != "", nil
+	case stepCheckPosition:
+		return strings.TrimSpace(user.Position) != "", nil
+	case stepCheckTimezone:
+		return model.GetPreferredTimezone(user.Timezone) != "", nil
+	case stepCheckPolicies:
+		pending, err := p.pendingPolicies(state)
+		return len(pending) == 0, err
+	case stepCheckQuiz:
+		return p.hasPassedQuiz(user.Id, check.Step)
+	}
+
+	channel, err := p.findOnboardingChannel(user, state, check.Channel)
+	if err != nil {
+		return false, err
+	}
+	if channel == nil {
+		// The step stays open for manual completion rather than passing
+		if _, logged := p.missingCheckChannels.LoadOrStore(check.Channel, true); !logged {
+			p.API.LogWarn("Step check names a channel that doesn't exist; the step has to be completed manually", "check", check.Kind, "channel", check.Channel)
+		}
+		return false, errCheckChannelNotFound
+	}
+
+	switch check.Kind {
+	case stepCheckChannelMember:
+		_, appErr := p.API.GetChannelMember(channel.Id, user.Id)
+		if appErr != nil && appErr.StatusCode == http.StatusNotFound {
+			return false, nil
+		}
+		if appErr != nil {
+			return false, appErr
+		}
+		return true, nil
+	case stepCheckChannelPost:
+		return p.hasPostedIn(user, state, channel)
+	}
+	return false, fmt.Errorf("unknown check %q", check.Kind)
+}
+
+// findOnboardingChannel looks up a channel by name in the team onboarding
+// started in, then in the user's other teams. It returns nil if no team has it.
+func (p *Plugin) findOnboardingChannel(user *model.User, state *OnboardingState, name string) (*model.Channel, error) {
+	var teamIDs []string
+	if state.TeamID != "" {
+		teamIDs = append(teamIDs, state.TeamID)
+	}
+	teams, appErr := p.API.GetTeamsForUser(user.Id)
+	if appErr != nil {
+		return nil, appErr
+	}
+	for _, team := range teams {
+		if team.Id != state.TeamID {
+			teamIDs = append(teamIDs, team.Id)
+		}
+	}
+
+	for _, teamID := range teamIDs {
+		channel, appErr := p.API.GetChannelByName(teamID, name, false)
+		if appErr == nil {
+			return channel, nil
+		}
+		if appErr.StatusCode != http.StatusNotFound {
+			return nil, appErr
+		}
+	}
+	return nil, nil
+}
+
+// hasPostedIn reports whether the user posted in the channel. Posts seen by
+// MessageHasBeenPosted are remembered on the state; older posts are searched.
+func (p *Plugin) hasPostedIn(user *model.User, state *OnboardingState, channel *model.Channel) (bool, error) {
+	if state.PostedChannels[channel.Id] {
+		return true, nil
+	}
+
+	posts, appErr := p.API.SearchPostsInTeam(channel.TeamId, []*model.SearchParams{{
+		InChannels:          []string{channel.Name},
+		FromUsers:           []string{user.Username},
+		SearchWithoutUserId: true,
+	}})
+	if appErr != nil {
+		return false, appErr
+	}
+	for _, post := range posts {
+		if post.ChannelId == channel.Id && post.UserId == user.Id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// hasChannelPostChecks reports whether any step checks for a channel post
+func hasChannelPostChecks(steps []StepDefinition) bool {
+	for _, step := range steps {
+		for _, check := range stepChecks(step) {
+			if check.Kind == stepCheckChannelPost {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// watchesChannelPosts reports whether an open step of the user checks for a
+// post in the channel
+func (p *Plugin) watchesChannelPosts(state *OnboardingState, channelName string) bool {
+	for _, step := range p.stepsForState(state) {
+		if state.CompletedSteps[step.ID] {
+			continue
+		}
+		for _, check := range stepChecks(step) {
+			if check.Kind == stepCheckChannelPost && check.Channel == channelName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// describeMissingChecks lists what the user still has to do for a step
+func (p *Plugin) describeMissingChecks(step StepDefinition, missing []stepCheck, language string) string {
+	tr := p.translationsFor(language)
+
+	lines := make([]string, 0, len(missing)+1)
+	lines = append(lines, fmt.Sprintf(tr.StepChecksMissing, step.Title.Get(language)))
+	for _, check := range missing {
+		var line string
+		switch check.Kind {
+		case stepCheckProfilePhoto:
+			line = tr.CheckMissingProfilePhoto
+		case stepCheckFullName:
+			line = tr.CheckMissingFullName
+		case stepCheckPosition:
+			line = tr.CheckMissingPosition
+		case stepCheckTimezone:
+			line = tr.CheckMissingTimezone
+		case stepCheckChannelMember:
+			line = fmt.Sprintf(tr.CheckMissingChannelMember, check.Channel)
+		case stepCheckChannelPost:
+			line = fmt.Sprintf(tr.CheckMissingChannelPost, check.Channel)
+		case stepCheckPolicies:
+			line = tr.CheckMissingPolicies
+		case stepCheckQuiz:
+			line = tr.CheckMissingQuiz
+		}
+		lines = append(lines, "- "+line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const onboardingWelcomedKVPrefix = "onboarding:welcomed:"
+
+// welcomeChannelForTeam returns the channel name for public welcomes in a
+// team, or "" when the team opted out.
+func (p *Plugin) welcomeChannelForTeam(team *model.Team) string {
+	channelName := strings.TrimSpace(p.getPluginSetting("WelcomeChannel", "town-square"))
+
+	if raw := strings.TrimSpace(p.getPluginSetting("WelcomeChannelOverrides", "")); raw != "" {
+		overrides, err := parseWelcomeChannelOverrides(raw)
+		if err != nil {
+			p.API.LogDebug("ignoring welcome channel overrides", "err", err.Error())
+		} else if override, ok := overrides[team.Name]; ok {
+			channelName = strings.TrimSpace(override)
+		}
+	}
+
+	return strings.TrimPrefix(channelName, "~")
+}
+
+// parseWelcomeChannelOverrides parses the team name -> channel name mapping.
+// An empty channel name opts the team out of public welcomes.
+func parseWelcomeChannelOverrides(raw string) (map[string]string, error) {
+	var overrides map[string]string
+	if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+		return nil, fmt.Errorf("parse welcome channel overrides: %w", err)
+	}
+	return overrides, nil
+}
+
+// postTeamWelcomes posts a public welcome on every team the user belongs to
+func (p *Plugin) postTeamWelcomes(user *model.User) {
+	teams, appErr := p.API.GetTeamsForUser(user.Id)
+	if appErr != nil {
+		p.API.LogWarn("failed to get teams for welcome post", "user_id", user.Id, "err", appErr.Error())
+		return
+	}
+
+	for _, team := range teams {
+		if err := p.postTeamWelcome(user, team); err != nil {
+			p.API.LogWarn("failed to post welcome", "user_id", user.Id, "team_id", team.Id, "err", err.Error())
+		}
+	}
+}
+
+// postTeamWelcome posts the public welcome for one team. It is idempotent:
+// every member is welcomed at most once per team.
+func (p *Plugin) postTeamWelcome(user *model.User, team *model.Team) error {
+	if user.IsBot {
+		return nil
+	}
+
+	channelName := p.welcomeChannelForTeam(team)
+	if channelName == "" {
+		return nil
+	}
+
+	channel, appErr := p.API.GetChannelByName(team.Id, channelName, false)
+	if appErr != nil {
+		return fmt.Errorf("get welcome channel %q: %w", channelName, appErr)
+	}
+
+	// Claim the welcome atomically so concurrent hooks don't post twice
+	key := onboardingWelcomedKVPrefix + team.Id + ":" + user.Id
+	claimed, appErr := p.API.KVSetWithOptions(key, []byte("1"), model.PluginKVSetOptions{Atomic: true, OldValue: nil})
+	if appErr != nil {
+		return fmt.Errorf("KVSetWithOptions: %w", appErr)
+	}
+	if !claimed {
+		return nil
+	}
+
+	post := &model.Post{
+		UserId:    p.botUserID,
+		ChannelId: channel.Id,
+		Message:   p.buildTeamWelcomeMessage(user, team),
+	}
+	if _, appErr := p.API.CreatePost(post); appErr != nil {
+		// Release the claim so a later join of the team tries again
+		if delErr := p.API.KVDelete(key); delErr != nil {
+			p.API.LogWarn("failed to release welcome claim", "user_id", user.Id, "team_id", team.Id, "err", delErr.Error())
+		}
+		return appErr
+	}
+	return nil
+}
+
+// buildTeamWelcomeMessage renders the admin template if set, otherwise the
+// translated default. Templates may use {name}, {username} and {team}.
+func (p *Plugin) buildTeamWelcomeMessage(user *model.User, team *model.Team) string {
+	template := strings.TrimSpace(p.getPluginSetting("WelcomeChannelMessage", ""))
+	if template == "" {
+		tr := p.getTranslations()
+		return fmt.Sprintf(tr.WelcomeChannelMessage, displayNameOf(user), user.Username, team.DisplayName)
+	}
+
+	return strings.NewReplacer(
+		"{name}", displayNameOf(user),
+		"{username}", user.Username,
+		"{team}", team.DisplayName,
+	).Replace(template)
+}